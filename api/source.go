@@ -2,36 +2,374 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
-	"github.com/Masterminds/semver"
+	"github.com/dpb587/bosh-release-resource/boshrelease"
 	"github.com/pkg/errors"
 )
 
+const DefaultTagName = "v{{.Version}}"
+
+// DefaultTokenUsername is used with token authentication when no username is
+// configured. GitHub accepts any username with a token.
+const DefaultTokenUsername = "x-access-token"
+
 type Source struct {
-	URI                string                 `json:"uri"`
-	Branch             string                 `json:"branch"`
-	Name               string                 `json:"name,omitempty"`
-	Version            string                 `json:"version,omitempty"`
-	DevReleases        bool                   `json:"dev_releases,omitempty"`
-	VersionConstraints *semver.Constraints    `json:"-"`
-	PrivateConfig      map[string]interface{} `json:"private_config,omitempty"`
-	PrivateKey         string                 `json:"private_key"`
+	URI                  string                    `json:"uri"`
+	Mirrors              []string                  `json:"-"`
+	Branch               string                    `json:"branch"`
+	LocalRepository      string                    `json:"local_repository,omitempty"`
+	Name                 string                    `json:"name,omitempty"`
+	Version              []string                  `json:"-"`
+	VersionAny           []string                  `json:"version_any,omitempty"`
+	SkipVersions         []string                  `json:"skip_versions,omitempty"`
+	PreReleases          string                    `json:"pre_releases,omitempty"`
+	VersionScheme        string                    `json:"version_scheme,omitempty"`
+	LatestPer            string                    `json:"latest_per,omitempty"`
+	LatestPerDepth       int                       `json:"-"`
+	Scheme               boshrelease.VersionScheme `json:"-"`
+	DevReleases          bool                      `json:"dev_releases,omitempty"`
+	VersionFilter        boshrelease.VersionFilter `json:"-"`
+	TagName              string                    `json:"tag_name,omitempty"`
+	TagNameTemplate      *template.Template        `json:"-"`
+	PrivateConfig        map[string]interface{}    `json:"private_config,omitempty"`
+	PrivateKey           string                    `json:"private_key"`
+	PrivateKeyPassphrase string                    `json:"private_key_passphrase,omitempty"`
+	SubmoduleKeys        []SubmoduleKey            `json:"submodule_private_keys,omitempty"`
+	KnownHosts           string                    `json:"known_hosts,omitempty"`
+	Username             string                    `json:"username,omitempty"`
+	Password             string                    `json:"password,omitempty"`
+	Token                string                    `json:"token,omitempty"`
+	Credentials          []Credential              `json:"credentials,omitempty"`
+	URLRewrites          []URLRewrite              `json:"url_rewrites,omitempty"`
+	Proxy                string                    `json:"proxy,omitempty"`
+	NoProxy              string                    `json:"no_proxy,omitempty"`
+	CACerts              []string                  `json:"ca_certs,omitempty"`
+	GitConfig            map[string]string         `json:"git_config,omitempty"`
+	CacheDir             string                    `json:"cache_dir,omitempty"`
+	CacheMaxAge          string                    `json:"cache_max_age,omitempty"`
+	CacheMaxSize         string                    `json:"cache_max_size,omitempty"`
+	CachePolicy          boshrelease.CachePolicy   `json:"-"`
+	Retry                *Retry                    `json:"retry,omitempty"`
+	RetryPolicy          boshrelease.RetryPolicy   `json:"-"`
+}
+
+type Retry struct {
+	Attempts       int    `json:"attempts,omitempty"`
+	InitialBackoff string `json:"initial_backoff,omitempty"`
+	MaxBackoff     string `json:"max_backoff,omitempty"`
 }
 
 func (s *Source) UnmarshalJSON(data []byte) error {
 	type unmarshal Source
-	if err := json.Unmarshal(data, (*unmarshal)(s)); err != nil {
+
+	// uri may be a single location or an ordered list of mirrors, and version
+	// may be a single constraint or a list
+	raw := struct {
+		*unmarshal
+		URI     json.RawMessage `json:"uri"`
+		Version json.RawMessage `json:"version"`
+	}{
+		unmarshal: (*unmarshal)(s),
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
 
-	if s.Version != "" {
-		constraints, err := semver.NewConstraint(s.Version)
-		if err != nil {
+	if len(raw.URI) > 0 && raw.URI[0] == '[' {
+		var uris []string
+
+		if err := json.Unmarshal(raw.URI, &uris); err != nil {
+			return errors.Wrap(err, "parsing uri")
+		} else if len(uris) == 0 {
+			return errors.New("parsing uri: at least one uri is required")
+		}
+
+		s.URI = uris[0]
+		s.Mirrors = uris[1:]
+	} else if len(raw.URI) > 0 {
+		if err := json.Unmarshal(raw.URI, &s.URI); err != nil {
+			return errors.Wrap(err, "parsing uri")
+		}
+	}
+
+	if len(raw.Version) > 0 && raw.Version[0] == '[' {
+		if err := json.Unmarshal(raw.Version, &s.Version); err != nil {
 			return errors.Wrap(err, "parsing version")
 		}
+	} else if len(raw.Version) > 0 {
+		var version string
+
+		if err := json.Unmarshal(raw.Version, &version); err != nil {
+			return errors.Wrap(err, "parsing version")
+		} else if version != "" {
+			s.Version = []string{version}
+		}
+	}
+
+	var err error
+
+	s.Scheme, err = boshrelease.ParseVersionScheme(s.VersionScheme)
+	if err != nil {
+		return errors.Wrap(err, "parsing version_scheme")
+	}
+
+	s.VersionFilter.Constraints, err = parseConstraints(s.Scheme, s.Version)
+	if err != nil {
+		return errors.Wrap(err, "parsing version")
+	}
+
+	s.VersionFilter.AnyConstraints, err = parseConstraints(s.Scheme, s.VersionAny)
+	if err != nil {
+		return errors.Wrap(err, "parsing version_any")
+	}
+
+	s.VersionFilter.Skip, err = parseConstraints(s.Scheme, s.SkipVersions)
+	if err != nil {
+		return errors.Wrap(err, "parsing skip_versions")
+	}
+
+	s.VersionFilter.PreReleases, err = boshrelease.ParsePreReleasePolicy(s.PreReleases)
+	if err != nil {
+		return errors.Wrap(err, "parsing pre_releases")
+	}
+
+	switch s.LatestPer {
+	case "":
+	case "major":
+		s.LatestPerDepth = 1
+	case "minor":
+		s.LatestPerDepth = 2
+	default:
+		return fmt.Errorf("parsing latest_per: unsupported value: %s (expected major or minor)", s.LatestPer)
+	}
+
+	if s.TagName == "" {
+		s.TagName = DefaultTagName
+	}
+
+	s.TagNameTemplate, err = template.New("tag_name").Parse(s.TagName)
+	if err != nil {
+		return errors.Wrap(err, "parsing tag_name")
+	}
+
+	if s.CacheMaxAge != "" {
+		maxAge, err := time.ParseDuration(s.CacheMaxAge)
+		if err != nil {
+			return errors.Wrap(err, "parsing cache_max_age")
+		}
+
+		s.CachePolicy.MaxAge = maxAge
+	}
+
+	s.RetryPolicy = boshrelease.DefaultRetryPolicy
+
+	if s.Retry != nil {
+		if s.Retry.Attempts > 0 {
+			s.RetryPolicy.Attempts = s.Retry.Attempts
+		}
+
+		if s.Retry.InitialBackoff != "" {
+			s.RetryPolicy.InitialBackoff, err = time.ParseDuration(s.Retry.InitialBackoff)
+			if err != nil {
+				return errors.Wrap(err, "parsing retry.initial_backoff")
+			}
+		}
+
+		if s.Retry.MaxBackoff != "" {
+			s.RetryPolicy.MaxBackoff, err = time.ParseDuration(s.Retry.MaxBackoff)
+			if err != nil {
+				return errors.Wrap(err, "parsing retry.max_backoff")
+			}
+		}
+	}
+
+	if s.CacheMaxSize != "" {
+		maxSize, err := parseByteSize(s.CacheMaxSize)
+		if err != nil {
+			return errors.Wrap(err, "parsing cache_max_size")
+		}
 
-		s.VersionConstraints = constraints
+		s.CachePolicy.MaxSize = maxSize
 	}
 
 	return nil
 }
+
+// MarshalJSON writes uri and version as they may be configured: a single
+// location or a list including mirrors, and a single constraint or a list of
+// constraints.
+func (s Source) MarshalJSON() ([]byte, error) {
+	type marshal Source
+
+	var uri interface{} = s.URI
+
+	if len(s.Mirrors) > 0 {
+		uri = append([]string{s.URI}, s.Mirrors...)
+	}
+
+	var version interface{}
+
+	if len(s.Version) == 1 {
+		version = s.Version[0]
+	} else if len(s.Version) > 1 {
+		version = s.Version
+	}
+
+	return json.Marshal(struct {
+		marshal
+		URI     interface{} `json:"uri"`
+		Version interface{} `json:"version,omitempty"`
+	}{
+		marshal: marshal(s),
+		URI:     uri,
+		Version: version,
+	})
+}
+
+func parseConstraints(scheme boshrelease.VersionScheme, values []string) ([]boshrelease.VersionConstraint, error) {
+	var constraints []boshrelease.VersionConstraint
+
+	for _, value := range values {
+		constraint, err := scheme.ParseConstraint(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", value)
+		}
+
+		constraints = append(constraints, constraint)
+	}
+
+	return constraints, nil
+}
+
+// parseByteSize parses a number of bytes with an optional binary unit suffix
+// (e.g. 512M or 10G).
+func parseByteSize(size string) (int64, error) {
+	units := map[string]int64{
+		"K": 1 << 10,
+		"M": 1 << 20,
+		"G": 1 << 30,
+		"T": 1 << 40,
+	}
+
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+
+	if l := len(value); l > 0 {
+		if unit, ok := units[value[l-1:]]; ok {
+			value = value[:l-1]
+			multiplier = unit
+		}
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+
+	return parsed * multiplier, nil
+}
+
+type SubmoduleKey struct {
+	URI                  string `json:"uri"`
+	PrivateKey           string `json:"private_key"`
+	PrivateKeyPassphrase string `json:"private_key_passphrase,omitempty"`
+}
+
+type Credential struct {
+	Host     string `json:"host"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+type URLRewrite struct {
+	URL       string `json:"url"`
+	InsteadOf string `json:"instead_of"`
+}
+
+// Repository returns a read-only repository of LocalRepository, if configured,
+// or a repository which clones URI.
+func (s Source) Repository() *boshrelease.Repository {
+	if s.LocalRepository != "" {
+		return boshrelease.NewReadOnlyRepository(s.LocalRepository, s.Branch, s.RepositoryConfig())
+	}
+
+	return boshrelease.NewRepository(s.URI, s.Branch, s.RepositoryConfig())
+}
+
+// Release uses the private config and version scheme of the source.
+func (s Source) Release(repository *boshrelease.Repository) *boshrelease.Release {
+	release := boshrelease.NewRelease(repository, s.PrivateConfig)
+
+	if s.Scheme != nil {
+		release.SetVersionScheme(s.Scheme)
+	}
+
+	return release
+}
+
+func (s Source) RepositoryConfig() boshrelease.RepositoryConfig {
+	config := boshrelease.RepositoryConfig{
+		PrivateKey:           s.PrivateKey,
+		PrivateKeyPassphrase: s.PrivateKeyPassphrase,
+		KnownHosts:           s.KnownHosts,
+		Username:             s.Username,
+		Password:             s.Password,
+		Proxy:                s.Proxy,
+		NoProxy:              s.NoProxy,
+		CACerts:              s.CACerts,
+		GitConfig:            s.GitConfig,
+		CacheDir:             s.CacheDir,
+		Mirrors:              s.Mirrors,
+		Retry:                s.RetryPolicy,
+	}
+
+	for _, key := range s.SubmoduleKeys {
+		config.SubmoduleKeys = append(config.SubmoduleKeys, boshrelease.SSHKey{
+			URI:        key.URI,
+			PrivateKey: key.PrivateKey,
+			Passphrase: key.PrivateKeyPassphrase,
+		})
+	}
+
+	for _, credential := range s.Credentials {
+		c := boshrelease.Credential{
+			Host:     credential.Host,
+			Username: credential.Username,
+			Password: credential.Password,
+		}
+
+		if credential.Token != "" {
+			c.Password = credential.Token
+
+			if c.Username == "" {
+				c.Username = DefaultTokenUsername
+			}
+		}
+
+		config.Credentials = append(config.Credentials, c)
+	}
+
+	for _, rewrite := range s.URLRewrites {
+		config.URLRewrites = append(config.URLRewrites, boshrelease.URLRewrite{
+			URL:       rewrite.URL,
+			InsteadOf: rewrite.InsteadOf,
+		})
+	}
+
+	if s.Token != "" {
+		config.Password = s.Token
+
+		if config.Username == "" {
+			config.Username = DefaultTokenUsername
+		}
+	}
+
+	return config
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// TagTemplateData is the data available to the tag_name and tag_message
+// templates.
+type TagTemplateData struct {
+	Name       string
+	Version    string
+	Commit     string
+	CommitHash string
+}
+
+func ExecuteTagTemplate(tmpl *template.Template, data TagTemplateData) (string, error) {
+	buf := &bytes.Buffer{}
+
+	err := tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
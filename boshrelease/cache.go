@@ -0,0 +1,239 @@
+package boshrelease
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	cachePrefix    = "bosh-release-"
+	worktreePrefix = "bosh-release-worktree-"
+)
+
+var cacheClonePattern = regexp.MustCompile(`^bosh-release-[0-9a-f]{40}$`)
+
+// CachePolicy limits the shared clones of a cache directory. Clones which have
+// not been pulled within MaxAge are removed first, then the least recently
+// pulled clones are removed until the total size is within MaxSize. A zero
+// value disables the limit.
+type CachePolicy struct {
+	MaxAge  time.Duration
+	MaxSize int64
+}
+
+func (p CachePolicy) IsZero() bool {
+	return p.MaxAge == 0 && p.MaxSize == 0
+}
+
+// CacheEntry describes a shared clone which was removed by CollectGarbage.
+type CacheEntry struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+type cacheClone struct {
+	CacheEntry
+	unlock func()
+}
+
+// CollectGarbage removes abandoned worktrees and any shared clones exceeding
+// the policy. Clones which are in use, either locked or with a worktree still
+// held open by another process, are never removed.
+func CollectGarbage(dir string, policy CachePolicy) ([]CacheEntry, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing cache dir")
+	}
+
+	var clones []cacheClone
+
+	defer func() {
+		for _, clone := range clones {
+			clone.unlock()
+		}
+	}()
+
+	var totalSize int64
+
+	for _, file := range files {
+		if !file.IsDir() || !cacheClonePattern.MatchString(file.Name()) {
+			continue
+		}
+
+		clonePath := filepath.Join(dir, file.Name())
+
+		clone, inUse, err := lockCacheClone(clonePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "inspecting %s", clonePath)
+		}
+
+		if clone == nil {
+			continue
+		}
+
+		totalSize += clone.Size
+
+		if inUse {
+			clone.unlock()
+
+			continue
+		}
+
+		clones = append(clones, *clone)
+	}
+
+	sort.Slice(clones, func(i, j int) bool {
+		return clones[i].LastUsed.Before(clones[j].LastUsed)
+	})
+
+	var removed []CacheEntry
+
+	for _, clone := range clones {
+		expired := policy.MaxAge > 0 && time.Since(clone.LastUsed) > policy.MaxAge
+		oversized := policy.MaxSize > 0 && totalSize > policy.MaxSize
+
+		if !expired && !oversized {
+			continue
+		}
+
+		err = os.RemoveAll(clone.Path)
+		if err != nil {
+			return removed, errors.Wrapf(err, "removing %s", clone.Path)
+		}
+
+		totalSize -= clone.Size
+		removed = append(removed, clone.CacheEntry)
+	}
+
+	return removed, nil
+}
+
+// lockCacheClone locks a shared clone and removes its abandoned worktrees. The
+// clone is nil if it is currently locked elsewhere. Otherwise, the caller is
+// responsible for unlocking it.
+func lockCacheClone(path string) (*cacheClone, bool, error) {
+	lockPath := path + ".lock"
+
+	// the lock file is kept, even once the clone is removed, since other
+	// processes may be waiting on it
+	fh, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "opening lock file")
+	}
+
+	fh.Close()
+
+	unlock, err := tryLock(lockPath)
+	if err != nil {
+		return nil, false, err
+	} else if unlock == nil {
+		return nil, false, nil
+	}
+
+	inUse, err := removeAbandonedWorktrees(path)
+	if err != nil {
+		unlock()
+
+		return nil, false, errors.Wrap(err, "removing abandoned worktrees")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		unlock()
+
+		return nil, false, err
+	}
+
+	size, err := dirSize(path)
+	if err != nil {
+		unlock()
+
+		return nil, false, errors.Wrap(err, "calculating size")
+	}
+
+	clone := &cacheClone{
+		CacheEntry: CacheEntry{
+			Path:     path,
+			Size:     size,
+			LastUsed: info.ModTime(),
+		},
+		unlock: unlock,
+	}
+
+	return clone, inUse, nil
+}
+
+// removeAbandonedWorktrees removes worktrees of a clone which are no longer
+// locked by the process which created them, returning whether any worktrees
+// remain in use.
+func removeAbandonedWorktrees(clonePath string) (bool, error) {
+	gitdirs, err := filepath.Glob(filepath.Join(clonePath, "worktrees", "*", "gitdir"))
+	if err != nil {
+		return false, err
+	}
+
+	var inUse bool
+
+	for _, gitdir := range gitdirs {
+		gitdirBytes, err := ioutil.ReadFile(gitdir)
+		if err != nil {
+			return false, err
+		}
+
+		worktree := filepath.Dir(strings.TrimSpace(string(gitdirBytes)))
+
+		if _, err := os.Stat(worktree); err == nil {
+			unlock, err := tryLock(worktree)
+			if err != nil {
+				return false, err
+			} else if unlock == nil {
+				inUse = true
+
+				continue
+			}
+
+			err = os.RemoveAll(worktree)
+			unlock()
+			if err != nil {
+				return false, err
+			}
+		}
+
+		err = os.RemoveAll(filepath.Dir(gitdir))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return inUse, nil
+}
+
+func dirSize(path string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
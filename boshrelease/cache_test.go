@@ -0,0 +1,137 @@
+package boshrelease_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+)
+
+var _ = Describe("CollectGarbage", func() {
+	var serverdir, cachedir string
+
+	BeforeEach(func() {
+		var err error
+
+		serverdir, err = testing.GenerateRemote("repo.git", "cd work && git push origin HEAD:other")
+		Expect(err).NotTo(HaveOccurred())
+
+		cachedir, err = ioutil.TempDir("", "bosh-release-resource-cache")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(serverdir)).To(Succeed())
+		Expect(os.RemoveAll(cachedir)).To(Succeed())
+	})
+
+	pull := func(branch string) *Repository {
+		repository := NewRepository(filepath.Join(serverdir, "repo.git"), branch, RepositoryConfig{
+			CacheDir: cachedir,
+		})
+
+		Expect(repository.Pull()).To(Succeed())
+
+		return repository
+	}
+
+	age := func(repository *Repository, age time.Duration) {
+		past := time.Now().Add(-age)
+
+		Expect(os.Chtimes(repository.CachePath(), past, past)).To(Succeed())
+	}
+
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+
+		return err == nil
+	}
+
+	It("uses the configured cache directory", func() {
+		repository := pull("master")
+		defer repository.Close()
+
+		Expect(filepath.Dir(repository.CachePath())).To(Equal(cachedir))
+		Expect(filepath.Dir(repository.Path())).To(Equal(cachedir))
+	})
+
+	It("removes clones which have not been used recently", func() {
+		stale := pull("master")
+		Expect(stale.Close()).To(Succeed())
+		age(stale, 48*time.Hour)
+
+		recent := pull("other")
+		Expect(recent.Close()).To(Succeed())
+
+		removed, err := CollectGarbage(cachedir, CachePolicy{MaxAge: 24 * time.Hour})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(HaveLen(1))
+		Expect(removed[0].Path).To(Equal(stale.CachePath()))
+
+		Expect(exists(stale.CachePath())).To(BeFalse())
+		Expect(exists(recent.CachePath())).To(BeTrue())
+
+		By("cloning again when next used", func() {
+			repository := pull("master")
+			Expect(repository.Close()).To(Succeed())
+		})
+	})
+
+	It("removes the least recently used clones until within the size limit", func() {
+		older := pull("master")
+		Expect(older.Close()).To(Succeed())
+		age(older, 2*time.Hour)
+
+		newer := pull("other")
+		Expect(newer.Close()).To(Succeed())
+		age(newer, time.Hour)
+
+		removed, err := CollectGarbage(cachedir, CachePolicy{MaxSize: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(HaveLen(2))
+		Expect(removed[0].Path).To(Equal(older.CachePath()))
+		Expect(removed[1].Path).To(Equal(newer.CachePath()))
+		Expect(removed[0].Size).To(BeNumerically(">", 0))
+	})
+
+	It("keeps clones with worktrees in use", func() {
+		repository := pull("master")
+		defer repository.Close()
+
+		age(repository, 48*time.Hour)
+
+		removed, err := CollectGarbage(cachedir, CachePolicy{MaxAge: time.Hour})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(BeEmpty())
+
+		Expect(exists(repository.Path())).To(BeTrue())
+	})
+
+	It("removes abandoned worktrees", func() {
+		repository := pull("master")
+		Expect(repository.Close()).To(Succeed())
+
+		abandoned := filepath.Join(cachedir, "abandoned")
+
+		err := testing.RunCommands(
+			repository.CachePath(),
+			[]string{
+				"git worktree add --quiet --detach " + abandoned + " refs/remotes/origin/master",
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		removed, err := CollectGarbage(cachedir, CachePolicy{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(BeEmpty())
+
+		Expect(exists(abandoned)).To(BeFalse())
+		Expect(exists(repository.CachePath())).To(BeTrue())
+	})
+})
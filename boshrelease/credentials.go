@@ -0,0 +1,120 @@
+package boshrelease
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// credentialHelper answers git credential requests from the environment so
+// secrets are never written to disk or embedded in remote URLs. Credentials
+// are matched by the requested host in order; an empty host matches any host.
+// Only hosts are written to the script, and secrets are printed without being
+// expanded again.
+func credentialHelper(credentials []Credential) string {
+	script := &bytes.Buffer{}
+
+	script.WriteString(`#!/bin/sh
+
+[ "$1" = get ] || exit 0
+
+host=
+
+while IFS== read -r key value ; do
+  [ "$key" = host ] && host="$value"
+done
+
+case "$host" in
+`)
+
+	for idx, credential := range credentials {
+		pattern := "*"
+
+		if credential.Host != "" {
+			pattern = fmt.Sprintf("'%s'", strings.Replace(credential.Host, "'", `'\''`, -1))
+		}
+
+		fmt.Fprintf(script, "  %s)\n    printf 'username=%%s\\npassword=%%s\\n' \"$BOSH_RELEASE_GIT_USERNAME_%d\" \"$BOSH_RELEASE_GIT_PASSWORD_%d\"\n    ;;\n", pattern, idx, idx)
+	}
+
+	script.WriteString("esac\n")
+
+	return script.String()
+}
+
+type Credential struct {
+	// Host is matched against the host (and port, if non-standard) of HTTP(S)
+	// remotes. An empty host matches any host.
+	Host     string
+	Username string
+	Password string
+}
+
+// credentials returns the per-host credentials followed by the repository
+// credentials, which are limited to the hosts of the repository and its
+// mirrors. URIs which are not HTTP(S) (e.g. SSH) never use them.
+func (r Repository) credentials() []Credential {
+	var credentials []Credential
+
+	credentials = append(credentials, r.config.Credentials...)
+
+	if r.config.Username != "" || r.config.Password != "" {
+		for _, uri := range append([]string{r.repository}, r.config.Mirrors...) {
+			parsed, err := url.Parse(uri)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				continue
+			}
+
+			credentials = append(credentials, Credential{
+				Host:     parsed.Host,
+				Username: r.config.Username,
+				Password: r.config.Password,
+			})
+		}
+	}
+
+	return credentials
+}
+
+// credentialHelperConfig writes the credential helper and returns the git
+// arguments and environment which use it. The caller is responsible for
+// calling the cleanup function once git has finished.
+func (r Repository) credentialHelperConfig(credentials []Credential) ([]string, []string, func(), error) {
+	dir, err := ioutil.TempDir("", "git-credentials")
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "creating tempdir")
+	}
+
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	helper := filepath.Join(dir, "helper")
+
+	err = ioutil.WriteFile(helper, []byte(credentialHelper(credentials)), 0700)
+	if err != nil {
+		cleanup()
+
+		return nil, nil, nil, errors.Wrap(err, "writing credential helper")
+	}
+
+	var env []string
+
+	for idx, credential := range credentials {
+		env = append(
+			env,
+			fmt.Sprintf("BOSH_RELEASE_GIT_USERNAME_%d=%s", idx, credential.Username),
+			fmt.Sprintf("BOSH_RELEASE_GIT_PASSWORD_%d=%s", idx, credential.Password),
+		)
+	}
+
+	args := []string{"-c", "credential.helper=", "-c", fmt.Sprintf("credential.helper=%s", helper)}
+
+	return args, env, cleanup, nil
+}
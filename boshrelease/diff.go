@@ -0,0 +1,322 @@
+package boshrelease
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ReleaseDiff describes what changed between two final versions of a release.
+type ReleaseDiff struct {
+	Name     string         `json:"name"`
+	From     ReleaseVersion `json:"from"`
+	To       ReleaseVersion `json:"to"`
+	Commits  []Commit       `json:"commits"`
+	Jobs     []ArtifactDiff `json:"jobs"`
+	Packages []ArtifactDiff `json:"packages"`
+	Blobs    []BlobChange   `json:"blobs"`
+}
+
+type ReleaseVersion struct {
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash,omitempty"`
+}
+
+// ArtifactDiff is a changed job or package along with its final builds and, for
+// jobs, the changed spec properties.
+type ArtifactDiff struct {
+	ArtifactChange
+
+	BeforeBuild *FinalBuild      `json:"before_build,omitempty"`
+	AfterBuild  *FinalBuild      `json:"after_build,omitempty"`
+	Properties  []PropertyChange `json:"properties,omitempty"`
+}
+
+type PropertyChange struct {
+	Name   string           `json:"name"`
+	Before *JobSpecProperty `json:"before,omitempty"`
+	After  *JobSpecProperty `json:"after,omitempty"`
+}
+
+func (c PropertyChange) Added() bool {
+	return c.Before == nil
+}
+
+func (c PropertyChange) Removed() bool {
+	return c.After == nil
+}
+
+type BlobChange struct {
+	Path   string `json:"path"`
+	Before *Blob  `json:"before,omitempty"`
+	After  *Blob  `json:"after,omitempty"`
+}
+
+func (c BlobChange) Added() bool {
+	return c.Before == nil
+}
+
+func (c BlobChange) Removed() bool {
+	return c.After == nil
+}
+
+// Diff compares two final versions of a release. Commits, job spec properties,
+// and blobs are only compared when the commit_hash of both versions is
+// available in the repository.
+func (r Release) Diff(name, from, to string) (ReleaseDiff, error) {
+	diff := ReleaseDiff{
+		Name: name,
+	}
+
+	fromManifest, err := r.Manifest(name, from)
+	if err != nil {
+		return diff, errors.Wrapf(err, "loading %s", from)
+	}
+
+	toManifest, err := r.Manifest(name, to)
+	if err != nil {
+		return diff, errors.Wrapf(err, "loading %s", to)
+	}
+
+	diff.From = ReleaseVersion{Version: fromManifest.Version, CommitHash: fromManifest.CommitHash}
+	diff.To = ReleaseVersion{Version: toManifest.Version, CommitHash: toManifest.CommitHash}
+
+	var fromCommit, toCommit string
+
+	if fromManifest.CommitHash != "" && toManifest.CommitHash != "" && r.repository.HasCommit(fromManifest.CommitHash) && r.repository.HasCommit(toManifest.CommitHash) {
+		fromCommit, toCommit = fromManifest.CommitHash, toManifest.CommitHash
+
+		diff.Commits, err = r.repository.GetCommitRange(fromCommit, toCommit)
+		if err != nil {
+			return diff, errors.Wrap(err, "loading commits")
+		}
+	}
+
+	manifestDiff := DiffManifests(fromManifest, toManifest)
+
+	diff.Jobs, err = r.diffFinalBuilds("jobs", manifestDiff.Jobs)
+	if err != nil {
+		return diff, err
+	}
+
+	diff.Packages, err = r.diffFinalBuilds("packages", manifestDiff.Packages)
+	if err != nil {
+		return diff, err
+	}
+
+	if fromCommit == "" {
+		return diff, nil
+	}
+
+	for idx, job := range diff.Jobs {
+		diff.Jobs[idx].Properties, err = r.diffJobProperties(job.Name, fromCommit, toCommit)
+		if err != nil {
+			return diff, errors.Wrapf(err, "comparing job %s", job.Name)
+		}
+	}
+
+	diff.Blobs, err = r.diffBlobs(fromCommit, toCommit)
+	if err != nil {
+		return diff, errors.Wrap(err, "comparing blobs")
+	}
+
+	return diff, nil
+}
+
+// diffFinalBuilds loads the final builds of both sides of the artifact changes.
+func (r Release) diffFinalBuilds(kind string, changes []ArtifactChange) ([]ArtifactDiff, error) {
+	var diffs []ArtifactDiff
+
+	for _, change := range changes {
+		diff := ArtifactDiff{ArtifactChange: change}
+
+		var err error
+
+		if change.Before != nil {
+			diff.BeforeBuild, err = r.FinalBuild(kind, change.Name, change.Before.Fingerprint)
+			if err != nil {
+				return nil, errors.Wrapf(err, "loading final build of %s", change.Name)
+			}
+		}
+
+		if change.After != nil {
+			diff.AfterBuild, err = r.FinalBuild(kind, change.Name, change.After.Fingerprint)
+			if err != nil {
+				return nil, errors.Wrapf(err, "loading final build of %s", change.Name)
+			}
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+func (r Release) diffJobProperties(name, fromCommit, toCommit string) ([]PropertyChange, error) {
+	before, err := r.JobSpec(fromCommit, name)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.JobSpec(toCommit, name)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]*PropertyChange{}
+
+	if before != nil {
+		for propertyName := range before.Properties {
+			property := before.Properties[propertyName]
+			changes[propertyName] = &PropertyChange{Name: propertyName, Before: &property}
+		}
+	}
+
+	if after != nil {
+		for propertyName := range after.Properties {
+			property := after.Properties[propertyName]
+
+			change, found := changes[propertyName]
+			if !found {
+				change = &PropertyChange{Name: propertyName}
+				changes[propertyName] = change
+			}
+
+			change.After = &property
+		}
+	}
+
+	var result []PropertyChange
+
+	for _, change := range changes {
+		if change.Before != nil && change.After != nil && reflect.DeepEqual(*change.Before, *change.After) {
+			continue
+		}
+
+		result = append(result, *change)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func (r Release) diffBlobs(fromCommit, toCommit string) ([]BlobChange, error) {
+	before, err := r.Blobs(fromCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.Blobs(toCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]*BlobChange{}
+
+	for blobPath := range before {
+		blob := before[blobPath]
+		changes[blobPath] = &BlobChange{Path: blobPath, Before: &blob}
+	}
+
+	for blobPath := range after {
+		blob := after[blobPath]
+
+		change, found := changes[blobPath]
+		if !found {
+			change = &BlobChange{Path: blobPath}
+			changes[blobPath] = change
+		}
+
+		change.After = &blob
+	}
+
+	var result []BlobChange
+
+	for _, change := range changes {
+		if change.Before != nil && change.After != nil && change.Before.SHA == change.After.SHA && change.Before.Size == change.After.Size {
+			continue
+		}
+
+		result = append(result, *change)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+// JSON renders the diff as indented JSON.
+func (d ReleaseDiff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Markdown renders the diff in the style of the generated release notes.
+func (d ReleaseDiff) Markdown() []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "# %s %s → %s\n\n", d.Name, d.From.Version, d.To.Version)
+
+	if len(d.Commits) > 0 {
+		fmt.Fprintf(buf, "## Commits\n\n")
+
+		for idx := len(d.Commits) - 1; idx >= 0; idx-- {
+			fmt.Fprintf(buf, " * %s %s\n", d.Commits[idx].Commit, d.Commits[idx].Subject)
+		}
+
+		fmt.Fprintf(buf, "\n")
+	}
+
+	writeArtifactDiffs(buf, "Jobs", d.Jobs)
+	writeArtifactDiffs(buf, "Packages", d.Packages)
+
+	if len(d.Blobs) > 0 {
+		fmt.Fprintf(buf, "## Blobs\n\n")
+
+		for _, change := range d.Blobs {
+			if change.Added() {
+				fmt.Fprintf(buf, " * added `%s`\n", change.Path)
+			} else if change.Removed() {
+				fmt.Fprintf(buf, " * removed `%s`\n", change.Path)
+			} else {
+				fmt.Fprintf(buf, " * updated `%s` (`%.7s` → `%.7s`)\n", change.Path, change.Before.SHA, change.After.SHA)
+			}
+		}
+
+		fmt.Fprintf(buf, "\n")
+	}
+
+	return append(bytes.TrimSpace(buf.Bytes()), '\n')
+}
+
+func writeArtifactDiffs(buf *bytes.Buffer, title string, diffs []ArtifactDiff) {
+	if len(diffs) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, "## %s\n\n", title)
+
+	for _, diff := range diffs {
+		writeArtifactChange(buf, diff.ArtifactChange)
+
+		for _, property := range diff.Properties {
+			if property.Added() {
+				fmt.Fprintf(buf, "    * added property `%s`\n", property.Name)
+			} else if property.Removed() {
+				fmt.Fprintf(buf, "    * removed property `%s`\n", property.Name)
+			} else {
+				fmt.Fprintf(buf, "    * updated property `%s`\n", property.Name)
+			}
+		}
+	}
+
+	fmt.Fprintf(buf, "\n")
+}
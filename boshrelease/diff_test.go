@@ -0,0 +1,121 @@
+package boshrelease_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Release", func() {
+	Describe("Diff", func() {
+		var releasedir string
+		var subject *Release
+		var commits []string
+
+		writeFile := func(name, contents string) {
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(releasedir, name)), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(releasedir, name), []byte(contents), 0644)).To(Succeed())
+		}
+
+		commit := func(message string) {
+			Expect(testing.RunCommands(releasedir, []string{
+				fmt.Sprintf("git add . && git -c user.name=test -c user.email=test@localhost commit -m %s", message),
+			})).To(Succeed())
+
+			stdout, err := testing.RunCommandStdout(releasedir, "git", "rev-parse", "HEAD")
+			Expect(err).NotTo(HaveOccurred())
+
+			commits = append(commits, strings.TrimSpace(stdout))
+		}
+
+		BeforeEach(func() {
+			var err error
+
+			releasedir, err = ioutil.TempDir("", "bosh-release-resource-diff")
+			Expect(err).NotTo(HaveOccurred())
+
+			commits = nil
+
+			Expect(testing.RunCommands(releasedir, []string{"git init ."})).To(Succeed())
+
+			writeFile("config/final.yml", "name: fake\n")
+			writeFile("config/blobs.yml", "golang/go.tgz:\n  size: 1\n  sha: aaa\nother.tgz:\n  size: 2\n  sha: bbb\n")
+			writeFile("jobs/fake1/spec", "name: fake1\nproperties:\n  port:\n    default: 80\n  unchanged:\n    description: same\n  removed:\n    description: gone\n")
+			commit("one")
+
+			writeFile("config/blobs.yml", "golang/go.tgz:\n  size: 1\n  sha: ccc\nother.tgz:\n  size: 2\n  sha: bbb\nnew.tgz:\n  size: 3\n  sha: ddd\n")
+			writeFile("jobs/fake1/spec", "name: fake1\nproperties:\n  port:\n    default: 8080\n  unchanged:\n    description: same\n  added:\n    default: {key: value}\n")
+			commit("two")
+
+			writeFile("releases/fake/fake-1.0.0.yml", fmt.Sprintf("name: fake\nversion: 1.0.0\ncommit_hash: %.7s\njobs:\n- name: fake1\n  fingerprint: aaa111\n  sha1: x\n- name: fake2\n  fingerprint: bbb222\n  sha1: x\npackages: []\n", commits[0]))
+			writeFile("releases/fake/fake-1.1.0.yml", fmt.Sprintf("name: fake\nversion: 1.1.0\ncommit_hash: %.7s\njobs:\n- name: fake1\n  fingerprint: ccc333\n  sha1: x\n- name: fake2\n  fingerprint: bbb222\n  sha1: x\npackages:\n- name: pkg1\n  fingerprint: ddd444\n  sha1: x\n", commits[1]))
+			writeFile(".final_builds/jobs/fake1/index.yml", "builds:\n  ccc333:\n    version: ccc333\n    sha1: fff\n    blobstore_id: blob-ccc333\nformat-version: \"2\"\n")
+			commit("finalize")
+
+			subject = NewRelease(NewLocalRepository(releasedir), nil)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(releasedir)).To(Succeed())
+		})
+
+		It("compares artifacts, properties, blobs, and commits", func() {
+			diff, err := subject.Diff("fake", "1.0.0", "1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(diff.From).To(Equal(ReleaseVersion{Version: "1.0.0", CommitHash: commits[0][0:7]}))
+			Expect(diff.To).To(Equal(ReleaseVersion{Version: "1.1.0", CommitHash: commits[1][0:7]}))
+
+			Expect(diff.Commits).To(HaveLen(1))
+			Expect(diff.Commits[0].Subject).To(Equal("two"))
+
+			Expect(diff.Jobs).To(HaveLen(1))
+			Expect(diff.Jobs[0].Name).To(Equal("fake1"))
+			Expect(diff.Jobs[0].BeforeBuild).To(BeNil())
+			Expect(diff.Jobs[0].AfterBuild).To(Equal(&FinalBuild{Version: "ccc333", SHA1: "fff", BlobstoreID: "blob-ccc333"}))
+
+			var properties []string
+
+			for _, property := range diff.Jobs[0].Properties {
+				properties = append(properties, property.Name)
+			}
+
+			Expect(properties).To(Equal([]string{"added", "port", "removed"}))
+			Expect(diff.Jobs[0].Properties[0].After.Default).To(Equal(map[string]interface{}{"key": "value"}))
+
+			Expect(diff.Packages).To(HaveLen(1))
+			Expect(diff.Packages[0].Added()).To(BeTrue())
+
+			Expect(diff.Blobs).To(HaveLen(2))
+			Expect(diff.Blobs[0].Path).To(Equal("golang/go.tgz"))
+			Expect(diff.Blobs[1].Path).To(Equal("new.tgz"))
+			Expect(diff.Blobs[1].Added()).To(BeTrue())
+		})
+
+		It("renders JSON and Markdown", func() {
+			diff, err := subject.Diff("fake", "1.0.0", "1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+
+			jsonBytes, err := diff.JSON()
+			Expect(err).NotTo(HaveOccurred())
+
+			var decoded map[string]interface{}
+			Expect(json.Unmarshal(jsonBytes, &decoded)).To(Succeed())
+			Expect(decoded["jobs"].([]interface{})[0]).To(HaveKeyWithValue("name", "fake1"))
+
+			markdown := string(diff.Markdown())
+			Expect(markdown).To(HavePrefix("# fake 1.0.0 → 1.1.0\n"))
+			Expect(markdown).To(ContainSubstring(" * updated `fake1` (`aaa111` → `ccc333`)\n    * added property `added`\n    * updated property `port`\n    * removed property `removed`\n"))
+			Expect(markdown).To(ContainSubstring("## Packages\n\n * added `pkg1`\n"))
+			Expect(markdown).To(ContainSubstring("## Blobs\n\n * updated `golang/go.tgz` (`aaa` → `ccc`)\n * added `new.tgz`\n"))
+		})
+	})
+})
@@ -0,0 +1,121 @@
+package boshrelease
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type URLRewrite struct {
+	// URL replaces the InsteadOf prefix of remote URLs.
+	URL       string
+	InsteadOf string
+}
+
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/cert.pem",
+}
+
+// environ returns the environment for git and bosh commands, including the
+// configured proxy, CA certificates, and git config overrides. The caller is
+// responsible for calling the cleanup function once the command has finished.
+func (r Repository) environ() ([]string, func(), error) {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cleanup := func() {}
+
+	if r.config.Proxy != "" {
+		for _, name := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
+			env = append(env, fmt.Sprintf("%s=%s", name, r.config.Proxy))
+		}
+	}
+
+	if r.config.NoProxy != "" {
+		for _, name := range []string{"no_proxy", "NO_PROXY"} {
+			env = append(env, fmt.Sprintf("%s=%s", name, r.config.NoProxy))
+		}
+	}
+
+	if len(r.config.GitConfig) > 0 || len(r.config.URLRewrites) > 0 {
+		var keys []string
+
+		for key := range r.config.GitConfig {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		var params []string
+
+		if existing := os.Getenv("GIT_CONFIG_PARAMETERS"); existing != "" {
+			params = append(params, existing)
+		}
+
+		for _, key := range keys {
+			params = append(params, shellQuote(fmt.Sprintf("%s=%s", key, r.config.GitConfig[key])))
+		}
+
+		for _, rewrite := range r.config.URLRewrites {
+			params = append(params, shellQuote(fmt.Sprintf("url.%s.insteadOf=%s", rewrite.URL, rewrite.InsteadOf)))
+		}
+
+		// the same mechanism git uses for propagating `-c` to subprocesses, so
+		// it also applies to submodules and git commands run by bosh
+		env = append(env, fmt.Sprintf("GIT_CONFIG_PARAMETERS=%s", strings.Join(params, " ")))
+	}
+
+	if len(r.config.CACerts) > 0 {
+		bundle, err := writeCABundle(r.config.CACerts)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "writing ca_certs")
+		}
+
+		cleanup = func() {
+			os.RemoveAll(bundle)
+		}
+
+		env = append(env, fmt.Sprintf("GIT_SSL_CAINFO=%s", bundle), fmt.Sprintf("SSL_CERT_FILE=%s", bundle))
+	}
+
+	return env, cleanup, nil
+}
+
+// writeCABundle writes the certificates after the system certificates since
+// the environment variables replace, rather than extend, the trusted
+// certificates.
+func writeCABundle(certs []string) (string, error) {
+	fh, err := ioutil.TempFile("", "git-caBundle")
+	if err != nil {
+		return "", errors.Wrap(err, "creating tempfile")
+	}
+
+	defer fh.Close()
+
+	for _, path := range systemCABundles {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		_, err = fmt.Fprintf(fh, "%s\n", strings.TrimSpace(string(bytes)))
+		if err != nil {
+			return fh.Name(), errors.Wrap(err, "writing system certificates")
+		}
+
+		break
+	}
+
+	for _, cert := range certs {
+		_, err = fmt.Fprintf(fh, "%s\n", strings.TrimSpace(cert))
+		if err != nil {
+			return fh.Name(), errors.Wrap(err, "writing certificate")
+		}
+	}
+
+	return fh.Name(), nil
+}
@@ -0,0 +1,156 @@
+package boshrelease
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PreReleasePolicy determines whether final versions with a prerelease (e.g.
+// 2.0.0-rc.1) are discovered.
+type PreReleasePolicy string
+
+const (
+	PreReleasesExclude PreReleasePolicy = "exclude"
+	PreReleasesInclude PreReleasePolicy = "include"
+	PreReleasesOnly    PreReleasePolicy = "only"
+)
+
+// ParsePreReleasePolicy parses a policy, defaulting to exclude.
+func ParsePreReleasePolicy(value string) (PreReleasePolicy, error) {
+	switch PreReleasePolicy(value) {
+	case "":
+		return PreReleasesExclude, nil
+	case PreReleasesExclude, PreReleasesInclude, PreReleasesOnly:
+		return PreReleasePolicy(value), nil
+	}
+
+	return "", fmt.Errorf("unsupported value: %s (expected include, exclude, or only)", value)
+}
+
+// VersionFilter selects which final versions are discovered.
+type VersionFilter struct {
+	// Constraints must all match.
+	Constraints []VersionConstraint
+
+	// AnyConstraints must have at least one match, if any are configured.
+	AnyConstraints []VersionConstraint
+
+	// Skip excludes versions matching any of its constraints, such as
+	// known-bad versions.
+	Skip []VersionConstraint
+
+	// PreReleases determines whether prerelease versions match. When
+	// included, constraints are checked against the version without its
+	// prerelease so that 2.0.0-rc.1 matches 2.x.
+	PreReleases PreReleasePolicy
+
+	// After, if set, only matches versions which are greater.
+	After Version
+}
+
+// Skipped returns whether the version is excluded regardless of other
+// constraints.
+func (f VersionFilter) Skipped(version Version) bool {
+	for _, constraint := range f.Skip {
+		if constraint.Check(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match returns whether the version is allowed by the prerelease policy, is
+// after After, and satisfies all of Constraints and any of AnyConstraints.
+func (f VersionFilter) Match(version Version) bool {
+	prerelease := version.Prerelease() != ""
+
+	switch f.PreReleases {
+	case PreReleasesInclude:
+		// allowed
+	case PreReleasesOnly:
+		if !prerelease {
+			return false
+		}
+	default:
+		if prerelease {
+			return false
+		}
+	}
+
+	if f.After != nil && version.Compare(f.After) <= 0 {
+		return false
+	}
+
+	constrained := version
+
+	if prerelease {
+		// constraints without a prerelease never match a prerelease version
+		constrained = version.Release()
+	}
+
+	for _, constraint := range f.Constraints {
+		if !constraint.Check(constrained) {
+			return false
+		}
+	}
+
+	if len(f.AnyConstraints) == 0 {
+		return true
+	}
+
+	for _, constraint := range f.AnyConstraints {
+		if constraint.Check(constrained) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LatestPerLine returns the greatest version of each line, where a line is the
+// first depth segments of a version (e.g. 1 for major or 2 for minor lines).
+// The versions must be ordered oldest first, and the result is as well.
+func LatestPerLine(versions []Version, depth int) []Version {
+	var result []Version
+
+	lines := map[string]int{}
+
+	for _, version := range versions {
+		line := versionLine(version, depth)
+
+		if idx, found := lines[line]; found {
+			result[idx] = version
+
+			continue
+		}
+
+		lines[line] = len(result)
+		result = append(result, version)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Compare(result[j]) < 0
+	})
+
+	return result
+}
+
+func versionLine(version Version, depth int) string {
+	segments := version.Segments()
+	line := make([]string, depth)
+
+	for idx := range line {
+		var segment int64
+
+		if idx < len(segments) {
+			segment = segments[idx]
+		}
+
+		line[idx] = strconv.FormatInt(segment, 10)
+	}
+
+	return strings.Join(line, ".")
+}
@@ -0,0 +1,149 @@
+package boshrelease_test
+
+import (
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VersionFilter", func() {
+	scheme := SemverScheme{}
+
+	parse := func(version string) Version {
+		parsed, err := scheme.Parse(version)
+		Expect(err).NotTo(HaveOccurred())
+
+		return parsed
+	}
+
+	constraints := func(values ...string) []VersionConstraint {
+		var result []VersionConstraint
+
+		for _, value := range values {
+			constraint, err := scheme.ParseConstraint(value)
+			Expect(err).NotTo(HaveOccurred())
+
+			result = append(result, constraint)
+		}
+
+		return result
+	}
+
+	matching := func(filter VersionFilter, versions ...string) []string {
+		var result []string
+
+		for _, version := range versions {
+			parsed := parse(version)
+
+			if !filter.Skipped(parsed) && filter.Match(parsed) {
+				result = append(result, version)
+			}
+		}
+
+		return result
+	}
+
+	It("matches everything by default", func() {
+		Expect(matching(VersionFilter{}, "1.0.0", "2.0.0")).To(Equal([]string{"1.0.0", "2.0.0"}))
+	})
+
+	It("requires all constraints", func() {
+		filter := VersionFilter{
+			Constraints: constraints(">=1.1", "<3.0.0"),
+		}
+
+		Expect(matching(filter, "1.0.0", "1.1.0", "2.0.0", "3.0.0")).To(Equal([]string{"1.1.0", "2.0.0"}))
+	})
+
+	It("requires any of the any constraints", func() {
+		filter := VersionFilter{
+			Constraints:    constraints(">=1.1"),
+			AnyConstraints: constraints("1.x", "3.x"),
+		}
+
+		Expect(matching(filter, "1.0.0", "1.1.0", "2.0.0", "3.0.0")).To(Equal([]string{"1.1.0", "3.0.0"}))
+	})
+
+	It("skips versions and constraints", func() {
+		filter := VersionFilter{
+			Skip: constraints("1.1.0", ">=2.0.0, <2.1.0"),
+		}
+
+		Expect(matching(filter, "1.0.0", "1.1.0", "2.0.0", "2.0.1", "2.1.0")).To(Equal([]string{"1.0.0", "2.1.0"}))
+	})
+
+	Describe("pre-releases", func() {
+		versions := []string{"1.9.0", "2.0.0-rc.1", "2.0.0", "2.0.1-rc.1"}
+
+		It("excludes them by default", func() {
+			Expect(matching(VersionFilter{}, versions...)).To(Equal([]string{"1.9.0", "2.0.0"}))
+		})
+
+		It("checks constraints without the prerelease when included", func() {
+			filter := VersionFilter{
+				Constraints: constraints("2.x"),
+				PreReleases: PreReleasesInclude,
+			}
+
+			Expect(matching(filter, versions...)).To(Equal([]string{"2.0.0-rc.1", "2.0.0", "2.0.1-rc.1"}))
+		})
+
+		It("only matches them when only", func() {
+			filter := VersionFilter{
+				PreReleases: PreReleasesOnly,
+				Skip:        constraints("2.0.1-rc.1"),
+			}
+
+			Expect(matching(filter, versions...)).To(Equal([]string{"2.0.0-rc.1"}))
+		})
+
+		It("compares by precedence after a version", func() {
+			filter := VersionFilter{
+				PreReleases: PreReleasesInclude,
+				After:       parse("2.0.0-rc.1"),
+			}
+
+			Expect(matching(filter, versions...)).To(Equal([]string{"2.0.0", "2.0.1-rc.1"}))
+		})
+
+		It("rejects unknown policies", func() {
+			_, err := ParsePreReleasePolicy("sometimes")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("LatestPerLine", func() {
+		latest := func(scheme VersionScheme, depth int, versions ...string) []string {
+			var parsed []Version
+
+			for _, version := range versions {
+				v, err := scheme.Parse(version)
+				Expect(err).NotTo(HaveOccurred())
+
+				parsed = append(parsed, v)
+			}
+
+			var result []string
+
+			for _, version := range LatestPerLine(parsed, depth) {
+				result = append(result, version.Original())
+			}
+
+			return result
+		}
+
+		versions := []string{"1.0.0", "1.4.2", "2.0.0", "2.0.1", "2.1.0", "3.0.0"}
+
+		It("keeps the latest of each major line", func() {
+			Expect(latest(scheme, 1, versions...)).To(Equal([]string{"1.4.2", "2.1.0", "3.0.0"}))
+		})
+
+		It("keeps the latest of each minor line", func() {
+			Expect(latest(scheme, 2, versions...)).To(Equal([]string{"1.0.0", "1.4.2", "2.0.1", "2.1.0", "3.0.0"}))
+		})
+
+		It("zero-pads dotted versions with fewer components", func() {
+			Expect(latest(DottedScheme{}, 2, "263", "263.0.1", "264.7")).To(Equal([]string{"263.0.1", "264.7"}))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package boshrelease
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// lockFile blocks until an exclusive lock of path is acquired. The lock is
+// held until the returned function is called.
+func lockFile(path string) (func(), error) {
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening lock file")
+	}
+
+	return flock(fh, syscall.LOCK_EX)
+}
+
+// lockDir acquires a shared lock of an existing directory to signal that it is
+// in use. The lock is held until the returned function is called or the
+// process exits.
+func lockDir(path string) (func(), error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening directory")
+	}
+
+	return flock(fh, syscall.LOCK_SH)
+}
+
+// tryLock attempts to acquire an exclusive lock of an existing path without
+// blocking. The returned function is nil if the path is locked elsewhere.
+func tryLock(path string) (func(), error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening lock")
+	}
+
+	unlock, err := flock(fh, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == nil {
+		return unlock, nil
+	} else if errors.Cause(err) == syscall.EWOULDBLOCK {
+		return nil, nil
+	}
+
+	return nil, err
+}
+
+func flock(fh *os.File, how int) (func(), error) {
+	err := syscall.Flock(int(fh.Fd()), how)
+	if err != nil {
+		fh.Close()
+
+		return nil, errors.Wrap(err, "acquiring lock")
+	}
+
+	return func() {
+		syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+		fh.Close()
+	}, nil
+}
@@ -0,0 +1,89 @@
+package boshrelease
+
+import (
+	"sort"
+)
+
+type ReleaseManifest struct {
+	Name               string                    `yaml:"name" json:"name"`
+	Version            string                    `yaml:"version" json:"version"`
+	CommitHash         string                    `yaml:"commit_hash" json:"commit_hash"`
+	UncommittedChanges bool                      `yaml:"uncommitted_changes" json:"uncommitted_changes"`
+	Jobs               []ReleaseManifestArtifact `yaml:"jobs" json:"jobs"`
+	Packages           []ReleaseManifestArtifact `yaml:"packages" json:"packages"`
+	CompiledPackages   []ReleaseManifestArtifact `yaml:"compiled_packages,omitempty" json:"compiled_packages,omitempty"`
+	License            *ReleaseManifestArtifact  `yaml:"license,omitempty" json:"license,omitempty"`
+}
+
+type ReleaseManifestArtifact struct {
+	Name         string   `yaml:"name" json:"name"`
+	Version      string   `yaml:"version" json:"version"`
+	Fingerprint  string   `yaml:"fingerprint" json:"fingerprint"`
+	SHA1         string   `yaml:"sha1" json:"sha1"`
+	Dependencies []string `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+}
+
+type ArtifactChange struct {
+	Name   string                   `json:"name"`
+	Before *ReleaseManifestArtifact `json:"before,omitempty"`
+	After  *ReleaseManifestArtifact `json:"after,omitempty"`
+}
+
+func (c ArtifactChange) Added() bool {
+	return c.Before == nil
+}
+
+func (c ArtifactChange) Removed() bool {
+	return c.After == nil
+}
+
+type ManifestDiff struct {
+	Jobs     []ArtifactChange `json:"jobs"`
+	Packages []ArtifactChange `json:"packages"`
+}
+
+// DiffManifests compares the jobs and packages of two release manifests by
+// name. Artifacts whose fingerprint did not change are omitted.
+func DiffManifests(before, after ReleaseManifest) ManifestDiff {
+	return ManifestDiff{
+		Jobs:     diffArtifacts(before.Jobs, after.Jobs),
+		Packages: diffArtifacts(before.Packages, after.Packages),
+	}
+}
+
+func diffArtifacts(before, after []ReleaseManifestArtifact) []ArtifactChange {
+	changes := map[string]*ArtifactChange{}
+
+	for idx := range before {
+		changes[before[idx].Name] = &ArtifactChange{
+			Name:   before[idx].Name,
+			Before: &before[idx],
+		}
+	}
+
+	for idx := range after {
+		change, found := changes[after[idx].Name]
+		if !found {
+			change = &ArtifactChange{Name: after[idx].Name}
+			changes[after[idx].Name] = change
+		}
+
+		change.After = &after[idx]
+	}
+
+	var result []ArtifactChange
+
+	for _, change := range changes {
+		if change.Before != nil && change.After != nil && change.Before.Fingerprint == change.After.Fingerprint {
+			continue
+		}
+
+		result = append(result, *change)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
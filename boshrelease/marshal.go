@@ -1,5 +1,7 @@
 package boshrelease
 
+import "fmt"
+
 type releaseConfig struct {
 	Name_      string `yaml:"name"`
 	FinalName_ string `yaml:"final_name"`
@@ -21,6 +23,33 @@ type releaseIndexBuild struct {
 	Version string `yaml:"version"`
 }
 
-type releaseVersion struct {
-	CommitHash string `yaml:"commit_hash"`
+type finalBuildsIndex struct {
+	Builds map[string]FinalBuild `yaml:"builds"`
+}
+
+type blobsConfig map[string]Blob
+
+// normalizeYAML converts the generic maps of parsed YAML to string-keyed maps
+// so they can be encoded as JSON.
+func normalizeYAML(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := map[string]interface{}{}
+
+		for k, v := range typed {
+			normalized[fmt.Sprintf("%v", k)] = normalizeYAML(v)
+		}
+
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(typed))
+
+		for idx, v := range typed {
+			normalized[idx] = normalizeYAML(v)
+		}
+
+		return normalized
+	}
+
+	return value
 }
@@ -0,0 +1,86 @@
+package boshrelease
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateReleaseNotes renders Markdown release notes for a finalized version
+// based on the commits and the job and package changes since the previous
+// final version.
+func (r Release) GenerateReleaseNotes(name, version string) ([]byte, error) {
+	manifest, err := r.Manifest(name, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading release")
+	}
+
+	previousVersion, err := r.PreviousVersion(name, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding previous version")
+	}
+
+	var previousManifest ReleaseManifest
+
+	if previousVersion != nil {
+		previousManifest, err = r.Manifest(name, previousVersion.Original())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading previous release")
+		}
+	}
+
+	buf := &bytes.Buffer{}
+
+	// without a resolvable base commit, the range would be the entire history
+	// rather than the changes since the previous version
+	hasRange := previousVersion == nil || (previousManifest.CommitHash != "" && r.repository.HasCommit(previousManifest.CommitHash))
+
+	if hasRange && manifest.CommitHash != "" && r.repository.HasCommit(manifest.CommitHash) {
+		commits, err := r.repository.GetCommitRange(previousManifest.CommitHash, manifest.CommitHash)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading commits")
+		}
+
+		if len(commits) > 0 {
+			fmt.Fprintf(buf, "## Commits\n\n")
+
+			for idx := len(commits) - 1; idx >= 0; idx-- {
+				fmt.Fprintf(buf, " * %s %s\n", commits[idx].Commit, commits[idx].Subject)
+			}
+
+			fmt.Fprintf(buf, "\n")
+		}
+	}
+
+	diff := DiffManifests(previousManifest, manifest)
+
+	writeArtifactChanges(buf, "Jobs", diff.Jobs)
+	writeArtifactChanges(buf, "Packages", diff.Packages)
+
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+func writeArtifactChanges(buf *bytes.Buffer, title string, changes []ArtifactChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, "## %s\n\n", title)
+
+	for _, change := range changes {
+		writeArtifactChange(buf, change)
+	}
+
+	fmt.Fprintf(buf, "\n")
+}
+
+func writeArtifactChange(buf *bytes.Buffer, change ArtifactChange) {
+	if change.Added() {
+		fmt.Fprintf(buf, " * added `%s`\n", change.Name)
+	} else if change.Removed() {
+		fmt.Fprintf(buf, " * removed `%s`\n", change.Name)
+	} else {
+		fmt.Fprintf(buf, " * updated `%s` (`%.7s` → `%.7s`)\n", change.Name, change.Before.Fingerprint, change.After.Fingerprint)
+	}
+}
@@ -0,0 +1,87 @@
+package boshrelease_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Release", func() {
+	Describe("GenerateReleaseNotes", func() {
+		var releasedir string
+		var subject *Release
+		var commits []string
+
+		writeFile := func(name, contents string) {
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(releasedir, name)), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(releasedir, name), []byte(contents), 0644)).To(Succeed())
+		}
+
+		commit := func(message string) {
+			Expect(testing.RunCommands(releasedir, []string{
+				fmt.Sprintf("git add . && git -c user.name=test -c user.email=test@localhost commit -m %s", message),
+			})).To(Succeed())
+
+			stdout, err := testing.RunCommandStdout(releasedir, "git", "rev-parse", "--short", "HEAD")
+			Expect(err).NotTo(HaveOccurred())
+
+			commits = append(commits, strings.TrimSpace(stdout))
+		}
+
+		writeRelease := func(version, commitHash string) {
+			writeFile(fmt.Sprintf("releases/fake/fake-%s.yml", version), fmt.Sprintf("name: fake\nversion: %s\ncommit_hash: %s\njobs: []\npackages: []\n", version, commitHash))
+		}
+
+		BeforeEach(func() {
+			var err error
+
+			releasedir, err = ioutil.TempDir("", "bosh-release-resource-notes")
+			Expect(err).NotTo(HaveOccurred())
+
+			commits = nil
+
+			Expect(testing.RunCommands(releasedir, []string{"git init ."})).To(Succeed())
+
+			writeFile("config/final.yml", "name: fake\n")
+			commit("one")
+
+			writeFile("src/file", "two\n")
+			commit("two")
+
+			writeFile("src/file", "three\n")
+			commit("three")
+
+			writeFile("releases/fake/index.yml", "builds:\n  a: {version: 1.0.0}\n  b: {version: 1.1.0}\nformat-version: \"2\"\n")
+			writeRelease("1.1.0", commits[2])
+
+			subject = NewRelease(NewLocalRepository(releasedir), nil)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(releasedir)).To(Succeed())
+		})
+
+		It("lists the commits since the previous version", func() {
+			writeRelease("1.0.0", commits[1])
+
+			notes, err := subject.GenerateReleaseNotes("fake", "1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(notes)).To(Equal(fmt.Sprintf("## Commits\n\n * %s three", commits[2])))
+		})
+
+		It("omits commits when the previous commit is not in the repository", func() {
+			writeRelease("1.0.0", "0123abc")
+
+			notes, err := subject.GenerateReleaseNotes("fake", "1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(notes)).NotTo(ContainSubstring("## Commits"))
+		})
+	})
+})
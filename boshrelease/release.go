@@ -1,7 +1,9 @@
 package boshrelease
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -20,15 +22,23 @@ var initialVersion = semver.MustParse("0.0.0")
 type Release struct {
 	repository    *Repository
 	privateConfig map[string]interface{}
+	versionScheme VersionScheme
 }
 
 func NewRelease(repository *Repository, privateConfig map[string]interface{}) *Release {
 	return &Release{
 		repository:    repository,
 		privateConfig: privateConfig,
+		versionScheme: SemverScheme{},
 	}
 }
 
+// SetVersionScheme changes how final versions are parsed and ordered. Dev
+// versions always use semver.
+func (r *Release) SetVersionScheme(scheme VersionScheme) {
+	r.versionScheme = scheme
+}
+
 func (r Release) Name() (string, error) {
 	bytes, err := ioutil.ReadFile(path.Join(r.repository.Path(), "config", "final.yml"))
 	if err != nil {
@@ -45,13 +55,21 @@ func (r Release) Name() (string, error) {
 	return config.Name(), nil
 }
 
-func (r Release) DevVersions(name, latestVersionCommit string) ([]*semver.Version, error) {
+// DevVersions returns a dev version for each commit since latestVersionCommit,
+// incrementing the latest final version of the commit. Final versions which
+// are not semver are not considered, and an error describing each of them is
+// returned for the caller to report.
+func (r Release) DevVersions(name, latestVersionCommit string) ([]*semver.Version, []error, error) {
 	commits, err := r.repository.GetCommitList(latestVersionCommit)
 	if err != nil {
-		return nil, errors.Wrap(err, "loading commits")
+		return nil, nil, errors.Wrap(err, "loading commits")
 	}
 
 	var versions []*semver.Version
+	var invalidVersions []error
+
+	// commits mostly share their final versions, so each is only reported once
+	reported := map[string]bool{}
 
 	for _, commit := range commits {
 		indexBytes, err := r.repository.Show(commit.Commit, path.Join("releases", name, "index.yml"))
@@ -63,67 +81,94 @@ func (r Release) DevVersions(name, latestVersionCommit string) ([]*semver.Versio
 			}
 
 			if err != nil {
-				return nil, errors.Wrapf(err, "loading releases index.yml for %s", commit.Commit)
+				return nil, nil, errors.Wrapf(err, "loading releases index.yml for %s", commit.Commit)
 			}
 		}
 
-		parsedVersions, err := r.parseReleaseIndex(indexBytes)
+		parsedVersions, invalid, err := r.parseReleaseIndex(indexBytes, SemverScheme{})
 		if err != nil {
-			return nil, errors.Wrapf(err, "parsing releases index.yml for %s", commit.Commit)
+			return nil, nil, errors.Wrapf(err, "parsing releases index.yml for %s", commit.Commit)
+		}
+
+		for _, err := range invalid {
+			if !reported[err.Error()] {
+				reported[err.Error()] = true
+				invalidVersions = append(invalidVersions, err)
+			}
 		}
 
 		var latestVersionForCommit *semver.Version
 
 		if l := len(parsedVersions); l > 0 {
-			latestVersionForCommit = parsedVersions[l-1]
+			latestVersionForCommit = parsedVersions[l-1].(semverVersion).Version
 		} else {
 			latestVersionForCommit = initialVersion
 		}
 
 		baseVersion, err := latestVersionForCommit.IncPatch().SetPrerelease(fmt.Sprintf("dev.%s.commit.%s", commit.CommitDate.Format("20060102T150405Z"), commit.Commit))
 		if err != nil {
-			return nil, errors.Wrapf(err, "creating version for %s", commit.Commit)
+			return nil, nil, errors.Wrapf(err, "creating version for %s", commit.Commit)
 		}
 
 		versions = append(versions, &baseVersion)
 	}
 
-	return versions, nil
+	return versions, invalidVersions, nil
 }
 
-func (r Release) Versions(name string, constraints []*semver.Constraints, latestVersion string) ([]*semver.Version, error) {
+// DevVersionCommit returns the commit referenced by the prerelease of a dev
+// version (e.g. 1.0.1-dev.20180613T040837Z.commit.dd7c33e1d).
+func DevVersionCommit(version *semver.Version) (string, error) {
+	prereleaseSplit := strings.Split(version.Prerelease(), ".")
+	if len(prereleaseSplit) < 4 || prereleaseSplit[2] != "commit" {
+		return "", errors.New("commit expected in prerelease")
+	}
+
+	return prereleaseSplit[3], nil
+}
+
+// DevVersionDirtyDigest returns the digest of uncommitted changes referenced
+// by a dirty dev version, or an empty string if the version is not dirty.
+func DevVersionDirtyDigest(version *semver.Version) string {
+	prereleaseSplit := strings.Split(version.Prerelease(), ".")
+	if len(prereleaseSplit) < 6 || prereleaseSplit[4] != "dirty" {
+		return ""
+	}
+
+	return prereleaseSplit[5]
+}
+
+// Versions returns the final versions matching the filter, ordered oldest
+// first. The latest version is included even if it does not match, unless it
+// is skipped. Versions which the scheme cannot parse are not included, and an
+// error describing each of them is returned for the caller to report.
+func (r Release) Versions(name string, filter VersionFilter, latestVersion string) ([]Version, []error, error) {
 	bytes, err := ioutil.ReadFile(path.Join(r.repository.Path(), "releases", name, "index.yml"))
 	if err != nil {
-		return nil, errors.Wrap(err, "reading index.yml")
+		return nil, nil, errors.Wrap(err, "reading index.yml")
 	}
 
-	parsedVersions, err := r.parseReleaseIndex(bytes)
+	parsedVersions, invalid, err := r.parseReleaseIndex(bytes, r.versionScheme)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing index.yml")
+		return nil, nil, errors.Wrap(err, "parsing index.yml")
 	}
 
-	var versions []*semver.Version
+	var versions []Version
 
 	for _, version := range parsedVersions {
-		if version.Original() == latestVersion {
+		if filter.Skipped(version) {
+			// never include, even if it is the latest version
+			continue
+		} else if version.Original() == latestVersion {
 			// always include
-		} else {
-			// rely on constraints
-			match := true
-
-			for _, constraint := range constraints {
-				match = match && constraint.Check(version)
-			}
-
-			if !match {
-				continue
-			}
+		} else if !filter.Match(version) {
+			continue
 		}
 
 		versions = append(versions, version)
 	}
 
-	return versions, nil
+	return versions, invalid, nil
 }
 
 func (r Release) CreateDevTarball(name, version, tarball string) error {
@@ -132,33 +177,106 @@ func (r Release) CreateDevTarball(name, version, tarball string) error {
 		return errors.Wrap(err, "parsing dev version")
 	}
 
-	prereleaseSplit := strings.Split(parsedVersion.Prerelease(), ".")
-	if prereleaseSplit[2] != "commit" {
-		return errors.New("commit expected in prerelease")
+	commit, err := DevVersionCommit(parsedVersion)
+	if err != nil {
+		return err
+	}
+
+	if digest := DevVersionDirtyDigest(parsedVersion); digest != "" {
+		// uncommitted changes cannot be checked out, so require the working tree
+		// to still match the version
+		err = r.verifyWorkingTree(commit, digest)
+		if err != nil {
+			return errors.Wrap(err, "verifying dirty dev release")
+		}
+	} else {
+		err = r.repository.Checkout(commit)
+		if err != nil {
+			return errors.Wrap(err, "checking out dev release")
+		}
 	}
 
-	err = r.repository.Checkout(prereleaseSplit[3])
+	return r.CreateWorkingTreeTarball(name, version, tarball)
+}
+
+// WorkingTreeVersion returns the dev version of HEAD. If the working tree has
+// uncommitted changes, the version has a dirty suffix with a digest of the
+// changes (e.g. 1.0.1-dev.20180613T040837Z.commit.dd7c33e1d.dirty.0c5e2a1b9f3d).
+func (r Release) WorkingTreeVersion(name string) (*semver.Version, error) {
+	// final versions which are not semver are skipped without warnings here
+	versions, _, err := r.DevVersions(name, "HEAD")
 	if err != nil {
-		return errors.Wrap(err, "checking out dev release")
+		return nil, errors.Wrap(err, "loading dev versions")
+	} else if len(versions) == 0 {
+		return nil, errors.New("no commits found")
 	}
 
-	err = r.writePrivateConfig()
+	version := versions[len(versions)-1]
+
+	dirty, err := r.repository.IsDirty()
 	if err != nil {
-		return errors.Wrap(err, "private.yml")
+		return nil, errors.Wrap(err, "checking status")
+	} else if !dirty {
+		return version, nil
 	}
 
-	cmd := exec.Command(
-		"bosh",
-		"create-release",
-		"--force",
-		"--tarball", tarball,
-		"--version", version,
-	)
-	cmd.Dir = r.repository.Path()
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
+	digest, err := r.repository.WorkingTreeDigest()
+	if err != nil {
+		return nil, errors.Wrap(err, "digesting changes")
+	}
 
-	err = cmd.Run()
+	dirtyVersion, err := version.SetPrerelease(fmt.Sprintf("%s.dirty.%s", version.Prerelease(), digest[0:12]))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating dirty version")
+	}
+
+	return &dirtyVersion, nil
+}
+
+func (r Release) verifyWorkingTree(commit, digest string) error {
+	expectedCommit, err := r.repository.ResolveCommit(commit)
+	if err != nil {
+		return err
+	}
+
+	actualCommit, err := r.repository.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+
+	if expectedCommit != actualCommit {
+		return fmt.Errorf("expected HEAD to be %s but found %s", commit, actualCommit)
+	}
+
+	actualDigest, err := r.repository.WorkingTreeDigest()
+	if err != nil {
+		return errors.Wrap(err, "digesting changes")
+	}
+
+	if !strings.HasPrefix(actualDigest, digest) {
+		return fmt.Errorf("expected changes with digest %s but found %.12s", digest, actualDigest)
+	}
+
+	return nil
+}
+
+// CreateWorkingTreeTarball creates a dev release from the current state of the
+// working tree, including any uncommitted changes.
+func (r Release) CreateWorkingTreeTarball(name, version, tarball string) error {
+	err := r.writePrivateConfig()
+	if err != nil {
+		return errors.Wrap(err, "private.yml")
+	}
+
+	err = r.retry(func() error {
+		return r.runBosh(
+			r.repository.Path(),
+			"create-release",
+			"--force",
+			"--tarball", tarball,
+			"--version", version,
+		)
+	})
 	if err != nil {
 		return errors.Wrap(err, "creating tarball")
 	}
@@ -172,17 +290,14 @@ func (r Release) CreateTarball(name, version, tarball string) error {
 		return errors.Wrap(err, "private.yml")
 	}
 
-	cmd := exec.Command(
-		"bosh",
-		"create-release",
-		"--tarball", tarball,
-		filepath.Join("releases", name, fmt.Sprintf("%s-%s.yml", name, version)),
-	)
-	cmd.Dir = r.repository.Path()
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
-
-	err = cmd.Run()
+	err = r.retry(func() error {
+		return r.runBosh(
+			r.repository.Path(),
+			"create-release",
+			"--tarball", tarball,
+			filepath.Join("releases", name, fmt.Sprintf("%s-%s.yml", name, version)),
+		)
+	})
 	if err != nil {
 		return errors.Wrap(err, "creating tarball")
 	}
@@ -196,38 +311,117 @@ func (r Release) FinalizeRelease(name, version, tarball string) (string, error)
 		return "", errors.Wrap(err, "private.yml")
 	}
 
-	cmd := exec.Command(
-		"bosh",
+	err = r.runBosh(
+		r.repository.Path(),
 		"finalize-release",
 		"--name", name,
 		"--version", version,
 		tarball,
 	)
-	cmd.Dir = r.repository.Path()
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
-
-	err = cmd.Run()
 	if err != nil {
 		return "", errors.Wrap(err, "finalizing release")
 	}
 
-	releaseManifestBytes, err := ioutil.ReadFile(path.Join(r.repository.Path(), "releases", name, fmt.Sprintf("%s-%s.yml", name, version)))
+	manifest, err := r.Manifest(name, version)
+	if err != nil {
+		return "", errors.Wrap(err, "loading finalized release")
+	}
+
+	return manifest.CommitHash, nil
+}
+
+func (r Release) Manifest(name, version string) (ReleaseManifest, error) {
+	var manifest ReleaseManifest
+
+	bytes, err := ioutil.ReadFile(path.Join(r.repository.Path(), "releases", name, fmt.Sprintf("%s-%s.yml", name, version)))
+	if err != nil {
+		return manifest, errors.Wrap(err, "reading release manifest")
+	}
+
+	err = yaml.Unmarshal(bytes, &manifest)
+	if err != nil {
+		return manifest, errors.Wrap(err, "parsing release manifest")
+	}
+
+	return manifest, nil
+}
+
+// PreviousVersion returns the greatest final version which is less than
+// version, or nil if there is none.
+func (r Release) PreviousVersion(name, version string) (Version, error) {
+	parsedVersion, err := r.versionScheme.Parse(version)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing version")
+	}
+
+	// versions which cannot be parsed cannot be compared, so they are ignored
+	versions, _, err := r.Versions(name, VersionFilter{PreReleases: PreReleasesInclude}, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "loading versions")
+	}
+
+	for idx := len(versions) - 1; idx >= 0; idx-- {
+		if versions[idx].Compare(parsedVersion) < 0 {
+			return versions[idx], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CreateCheckoutTarball creates a dev release from a separate checkout of the
+// release repository, such as one provided by a pipeline.
+func (r Release) CreateCheckoutTarball(dir, tarball string) error {
+	err := r.writePrivateConfigTo(dir)
+	if err != nil {
+		return errors.Wrap(err, "private.yml")
+	}
+
+	err = r.retry(func() error {
+		return r.runBosh(dir, "create-release", "--force", "--tarball", tarball)
+	})
+	if err != nil {
+		return errors.Wrap(err, "creating tarball")
+	}
+
+	return nil
+}
+
+func (r Release) runBosh(dir string, args ...string) error {
+	env, cleanup, err := r.repository.environ()
 	if err != nil {
-		return "", errors.Wrap(err, "reading finalized release")
+		return errors.Wrap(err, "preparing environment")
 	}
 
-	var parsed releaseVersion
+	defer cleanup()
 
-	err = yaml.Unmarshal(releaseManifestBytes, &parsed)
+	output := &bytes.Buffer{}
+
+	cmd := exec.Command("bosh", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = io.MultiWriter(os.Stderr, output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, output)
+
+	err = cmd.Run()
 	if err != nil {
-		return "", errors.Wrap(err, "parsing finalized release")
+		return commandError{err: err, output: output.String()}
 	}
 
-	return parsed.CommitHash, nil
+	return nil
+}
+
+// retry retries transient failures, such as blob downloads, according to the
+// retry policy of the repository.
+func (r Release) retry(fn func() error) error {
+	return r.repository.config.Retry.Do(fn)
 }
 
 func (r Release) writePrivateConfig() error {
+	return r.writePrivateConfigTo(r.repository.Path())
+}
+
+func (r Release) writePrivateConfigTo(dir string) error {
 	if r.privateConfig == nil {
 		return nil
 	}
@@ -237,7 +431,7 @@ func (r Release) writePrivateConfig() error {
 		return errors.Wrap(err, "marshalling private.yml")
 	}
 
-	err = ioutil.WriteFile(path.Join(r.repository.Path(), "config", "private.yml"), bytes, 0700)
+	err = ioutil.WriteFile(path.Join(dir, "config", "private.yml"), bytes, 0700)
 	if err != nil {
 		return errors.Wrap(err, "writing private.yml")
 	}
@@ -245,26 +439,33 @@ func (r Release) writePrivateConfig() error {
 	return nil
 }
 
-func (r Release) parseReleaseIndex(bytes []byte) ([]*semver.Version, error) {
+// parseReleaseIndex returns the versions of the index, ordered oldest first,
+// along with an error for each version which the scheme cannot parse.
+func (r Release) parseReleaseIndex(bytes []byte, scheme VersionScheme) ([]Version, []error, error) {
 	var index releaseIndex
 
 	err := yaml.Unmarshal(bytes, &index)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing index.yml")
+		return nil, nil, errors.Wrap(err, "parsing index.yml")
 	}
 
-	var versions []*semver.Version
+	var versions []Version
+	var invalid []error
 
 	for _, build := range index.Builds {
-		version, err := semver.NewVersion(build.Version)
+		version, err := scheme.Parse(build.Version)
 		if err != nil {
-			return nil, errors.Wrapf(err, "parsing version %s", build.Version)
+			invalid = append(invalid, errors.Wrapf(err, "parsing version %s", build.Version))
+
+			continue
 		}
 
 		versions = append(versions, version)
 	}
 
-	sort.Sort(semver.Collection(versions))
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) < 0
+	})
 
-	return versions, nil
+	return versions, invalid, nil
 }
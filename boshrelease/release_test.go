@@ -0,0 +1,54 @@
+package boshrelease_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Release", func() {
+	Describe("DevVersions", func() {
+		var releasedir string
+		var subject *Release
+
+		BeforeEach(func() {
+			var err error
+
+			releasedir, err = ioutil.TempDir("", "bosh-release-resource-dev-versions")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(releasedir, "releases", "fake"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(releasedir, "releases", "fake", "index.yml"), []byte("builds:\n  a: {version: 1.0.0}\n  b: {version: latest}\n  c: {version: 1.1.0}\nformat-version: \"2\"\n"), 0644)).To(Succeed())
+
+			Expect(testing.RunCommands(releasedir, []string{
+				"git init .",
+				"git add . && git -c user.name=test -c user.email=test@localhost commit -m one",
+				"git -c user.name=test -c user.email=test@localhost commit --allow-empty -m two",
+				"git -c user.name=test -c user.email=test@localhost commit --allow-empty -m three",
+			})).To(Succeed())
+
+			subject = NewRelease(NewLocalRepository(releasedir), nil)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(releasedir)).To(Succeed())
+		})
+
+		It("skips final versions which are not semver", func() {
+			versions, invalid, err := subject.DevVersions("fake", "HEAD~1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(HaveLen(2))
+			Expect(versions[0].Major()).To(BeEquivalentTo(1))
+			Expect(versions[0].Minor()).To(BeEquivalentTo(1))
+			Expect(versions[0].Patch()).To(BeEquivalentTo(1))
+
+			Expect(invalid).To(HaveLen(1))
+			Expect(invalid[0].Error()).To(ContainSubstring("parsing version latest"))
+		})
+	})
+})
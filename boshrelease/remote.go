@@ -0,0 +1,32 @@
+package boshrelease
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var scpLikeRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// ParseRemote extracts the host and repository path from a git remote URL
+// (e.g. git@github.com:owner/repo.git or https://github.com/owner/repo.git).
+func ParseRemote(remote string) (string, string, bool) {
+	var host, path string
+
+	if parsed, err := url.Parse(remote); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		host = parsed.Hostname()
+		path = parsed.Path
+	} else if match := scpLikeRemote.FindStringSubmatch(remote); match != nil {
+		host = match[1]
+		path = match[2]
+	} else {
+		return "", "", false
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return "", "", false
+	}
+
+	return host, path, true
+}
@@ -0,0 +1,36 @@
+package boshrelease_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+)
+
+var _ = Describe("ParseRemote", func() {
+	It("parses scp-like remotes", func() {
+		host, path, ok := ParseRemote("git@github.com:dpb587/fake-release.git")
+		Expect(ok).To(BeTrue())
+		Expect(host).To(Equal("github.com"))
+		Expect(path).To(Equal("dpb587/fake-release"))
+	})
+
+	It("parses https remotes", func() {
+		host, path, ok := ParseRemote("https://github.com/dpb587/fake-release.git")
+		Expect(ok).To(BeTrue())
+		Expect(host).To(Equal("github.com"))
+		Expect(path).To(Equal("dpb587/fake-release"))
+	})
+
+	It("parses ssh remotes with nested paths", func() {
+		host, path, ok := ParseRemote("ssh://git@gitlab.example.com:2222/group/subgroup/fake-release")
+		Expect(ok).To(BeTrue())
+		Expect(host).To(Equal("gitlab.example.com"))
+		Expect(path).To(Equal("group/subgroup/fake-release"))
+	})
+
+	It("ignores local paths", func() {
+		_, _, ok := ParseRemote("/tmp/fake-release")
+		Expect(ok).To(BeFalse())
+	})
+})
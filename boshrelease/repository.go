@@ -9,91 +9,441 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+type RepositoryConfig struct {
+	PrivateKey           string
+	PrivateKeyPassphrase string
+
+	// SubmoduleKeys are used instead of PrivateKey for SSH remotes matching
+	// their URI (e.g. deploy keys of submodules).
+	SubmoduleKeys []SSHKey
+
+	// KnownHosts enables strict host key checking for SSH remotes when
+	// configured. Otherwise, host keys are not verified.
+	KnownHosts string
+
+	// Username and Password are used for HTTP(S) remotes on the same host as
+	// the repository. Credentials are used for other hosts, such as those of
+	// submodules.
+	Username    string
+	Password    string
+	Credentials []Credential
+
+	// URLRewrites are applied to every remote, including submodules.
+	URLRewrites []URLRewrite
+
+	// Proxy, CACerts, and GitConfig apply to every git and bosh command.
+	Proxy     string
+	NoProxy   string
+	CACerts   []string
+	GitConfig map[string]string
+
+	// CacheDir contains the shared clones and worktrees (default is the
+	// system temp directory).
+	CacheDir string
+
+	// Retry applies to fetching, pushing, and submodule updates.
+	Retry RetryPolicy
+
+	// Mirrors are alternate locations of the repository which are tried, in
+	// order, when fetching from the repository fails. Pushes always use the
+	// repository.
+	Mirrors []string
+}
+
+// Repository operates on a worktree of a bare clone which is cached and shared
+// between processes. The shared clone is only modified while its lock is held
+// and has no working tree of its own, so each worktree may be used
+// independently.
 type Repository struct {
 	repository string
 	branch     string
+	cachedir   string
+	localdir   string
 	tmpdir     string
-	privateKey string
+	config     RepositoryConfig
+
+	authorName  string
+	authorEmail string
+
+	unlockWorktree func()
+	remote         string
+	skipSubmodules bool
 }
 
-func NewRepository(repository, branch, privateKey string) *Repository {
+func NewRepository(repository, branch string, config RepositoryConfig) *Repository {
 	cs := sha1.New()
 	cs.Write([]byte(repository))
 	cs.Write([]byte(branch))
 
+	if config.CacheDir == "" {
+		config.CacheDir = os.TempDir()
+	}
+
 	return &Repository{
 		repository: repository,
 		branch:     branch,
-		privateKey: privateKey,
-		tmpdir:     path.Join(os.TempDir(), fmt.Sprintf("bosh-release-%x", cs.Sum(nil))),
+		config:     config,
+		cachedir:   path.Join(config.CacheDir, fmt.Sprintf("%s%x", cachePrefix, cs.Sum(nil))),
+	}
+}
+
+// NewLocalRepository refers to an existing checkout. It is intended for
+// inspecting the checkout, so Pull should not be used.
+func NewLocalRepository(path string) *Repository {
+	return &Repository{
+		tmpdir: path,
 	}
 }
 
+// NewReadOnlyRepository refers to an existing checkout which is used instead
+// of a shared clone. Pull adds a separate worktree of the branch (or HEAD, if
+// empty) without fetching, so the working tree, index, refs, and config of the
+// checkout are never modified. Committing and tagging are not supported.
+func NewReadOnlyRepository(path, branch string, config RepositoryConfig) *Repository {
+	if config.CacheDir == "" {
+		config.CacheDir = os.TempDir()
+	}
+
+	return &Repository{
+		branch:   branch,
+		config:   config,
+		localdir: path,
+	}
+}
+
+// Path returns the worktree of the repository, which is only available once
+// pulled.
 func (r Repository) Path() string {
 	return r.tmpdir
 }
 
-func (r Repository) Pull() error {
-	var args []string
+// CachePath returns the shared clone of the repository.
+func (r Repository) CachePath() string {
+	return r.cachedir
+}
 
-	if _, err := os.Stat(path.Join(r.tmpdir, ".git")); os.IsNotExist(err) {
-		args = []string{"clone", "--quiet", "--recurse-submodules", r.repository}
+// Pull updates the shared clone and checks out the latest commit of the branch
+// in a worktree which is private to this repository. The worktree should be
+// removed with Close once no longer needed.
+func (r *Repository) Pull() error {
+	if r.localdir != "" {
+		ref := "HEAD"
 
 		if r.branch != "" {
-			args = append(args, "--branch", r.branch)
+			ref = r.branch
 		}
 
-		args = append(args, ".")
+		return r.checkoutWorktree(r.localdir, ref)
+	} else if r.cachedir == "" {
+		return errors.New("pulling a local repository is not supported")
+	}
 
-		err = os.MkdirAll(r.tmpdir, 0700)
+	unlock, err := r.lock()
+	if err != nil {
+		return errors.Wrap(err, "locking repository cache")
+	}
+
+	defer unlock()
+
+	if _, err := os.Stat(path.Join(r.cachedir, "HEAD")); os.IsNotExist(err) {
+		// replaces anything unusable (e.g. an interrupted clone)
+		_, err = r.fetchMirrors(func(uri string) error {
+			// starts over after any partial clone
+			err := os.RemoveAll(r.cachedir)
+			if err != nil {
+				return errors.Wrap(err, "removing local repo")
+			}
+
+			err = os.MkdirAll(r.cachedir, 0700)
+			if err != nil {
+				return errors.Wrap(err, "mkdir local repo")
+			}
+
+			return r.runDir(r.cachedir, os.Stderr, "clone", "--quiet", "--bare", uri, ".")
+		})
 		if err != nil {
-			return errors.Wrap(err, "mkdir local repo")
+			os.RemoveAll(r.cachedir)
+
+			return errors.Wrap(err, "fetching repository")
 		}
-	} else {
-		args = []string{"pull", "--ff-only", "--quiet", r.repository}
 
-		if r.branch != "" {
-			args = append(args, r.branch)
+		// origin is always the repository, regardless of the mirror it was
+		// cloned from, so pushes go to the repository
+		err = r.runDir(r.cachedir, os.Stderr, "config", "remote.origin.url", r.repository)
+		if err != nil {
+			return errors.Wrap(err, "configuring remote")
+		}
+
+		err = r.runDir(r.cachedir, os.Stderr, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+		if err != nil {
+			return errors.Wrap(err, "configuring remote")
 		}
 	}
 
-	err := r.run(args...)
+	// the same mirror is used for every ref so they are consistent
+	remote, err := r.fetchMirrors(func(uri string) error {
+		return r.runDir(r.cachedir, os.Stderr, "fetch", "--quiet", "--prune", "--force", "--tags", uri, "+refs/heads/*:refs/remotes/origin/*")
+	})
 	if err != nil {
 		return errors.Wrap(err, "fetching repository")
 	}
 
-	// TODO reset to handle force push?
+	r.remote = remote
+
+	// the modification time tracks usage for garbage collection
+	now := time.Now()
+
+	err = os.Chtimes(r.cachedir, now, now)
+	if err != nil {
+		return errors.Wrap(err, "touching local repo")
+	}
+
+	branch := r.branch
+
+	if branch == "" {
+		// the default branch of the remote, as of the clone
+		stdout := &bytes.Buffer{}
+
+		err = r.runDir(r.cachedir, stdout, "symbolic-ref", "HEAD")
+		if err != nil {
+			return errors.Wrap(err, "resolving default branch")
+		}
+
+		branch = strings.TrimPrefix(strings.TrimSpace(stdout.String()), "refs/heads/")
+	}
+
+	ref := fmt.Sprintf("refs/remotes/origin/%s", branch)
+
+	return r.checkoutWorktree(r.cachedir, ref)
+}
+
+// fetchMirrors calls fetch with the repository, then each mirror, until one
+// succeeds. The location which succeeded is returned.
+func (r Repository) fetchMirrors(fetch func(uri string) error) (string, error) {
+	uris := append([]string{r.repository}, r.config.Mirrors...)
+
+	var failures []string
+
+	for _, uri := range uris {
+		err := r.config.Retry.Do(func() error {
+			return fetch(uri)
+		})
+		if err == nil {
+			return uri, nil
+		} else if len(uris) == 1 {
+			return "", err
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %s", uri, err))
+	}
+
+	return "", fmt.Errorf("all mirrors failed: %s", strings.Join(failures, "; "))
+}
+
+// Remote returns the location which served the most recent Pull, which may be
+// a mirror of the repository.
+func (r Repository) Remote() string {
+	return r.remote
+}
+
+// checkoutWorktree checks out the ref in the worktree, adding the worktree to
+// gitdir if necessary. The caller is responsible for locking gitdir.
+func (r *Repository) checkoutWorktree(gitdir, ref string) error {
+	var err error
+
+	if r.tmpdir == "" {
+		if gitdir == r.cachedir {
+			// worktrees are locked until closed, so unlocked worktrees belong to
+			// processes which exited without closing them (e.g. after a failure)
+			_, err = removeAbandonedWorktrees(gitdir)
+			if err != nil {
+				return errors.Wrap(err, "removing abandoned worktrees")
+			}
+		}
+
+		tmpdir, err := ioutil.TempDir(r.config.CacheDir, worktreePrefix)
+		if err != nil {
+			return errors.Wrap(err, "creating worktree dir")
+		}
+
+		// held until closed so garbage collection can detect worktrees which
+		// are still in use
+		unlockWorktree, err := lockDir(tmpdir)
+		if err != nil {
+			os.RemoveAll(tmpdir)
+
+			return errors.Wrap(err, "locking worktree")
+		}
+
+		err = r.runDir(gitdir, os.Stderr, "worktree", "add", "--quiet", "--detach", tmpdir, ref)
+		if err != nil {
+			unlockWorktree()
+			os.RemoveAll(tmpdir)
+
+			return errors.Wrap(err, "adding worktree")
+		}
+
+		r.tmpdir = tmpdir
+		r.unlockWorktree = unlockWorktree
+	} else {
+		err = r.run("checkout", "--quiet", "--detach", ref)
+		if err != nil {
+			return errors.Wrap(err, "checking out")
+		}
+	}
+
+	// submodules are initialized while locked since they share the config of
+	// the clone
+	err = r.updateSubmodules()
+	if err != nil {
+		return errors.Wrap(err, "updating submodules")
+	}
 
 	return nil
 }
 
-func (r Repository) Push() error {
-	return errors.New("TODO")
+// Close removes the worktree. The shared clone is kept for later use.
+func (r *Repository) Close() error {
+	gitdir := r.cachedir
+
+	if r.localdir != "" {
+		gitdir = r.localdir
+	}
+
+	if gitdir == "" || r.tmpdir == "" {
+		return nil
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return errors.Wrap(err, "locking repository cache")
+	}
+
+	defer unlock()
+
+	if r.localdir != "" {
+		// only the metadata of this worktree is removed since the checkout may
+		// have other worktrees of its own
+		gitfileBytes, err := ioutil.ReadFile(path.Join(r.tmpdir, ".git"))
+		if err != nil {
+			return errors.Wrap(err, "reading worktree metadata")
+		}
+
+		err = os.RemoveAll(strings.TrimSpace(strings.TrimPrefix(string(gitfileBytes), "gitdir:")))
+		if err != nil {
+			return errors.Wrap(err, "removing worktree metadata")
+		}
+	}
+
+	err = os.RemoveAll(r.tmpdir)
+	if err != nil {
+		return errors.Wrap(err, "removing worktree")
+	}
+
+	r.unlockWorktree()
+	r.unlockWorktree = nil
+	r.tmpdir = ""
+
+	if r.localdir != "" {
+		return nil
+	} else if _, err := os.Stat(gitdir); os.IsNotExist(err) {
+		return nil
+	}
+
+	err = r.runDir(gitdir, os.Stderr, "worktree", "prune")
+	if err != nil {
+		return errors.Wrap(err, "pruning worktrees")
+	}
+
+	return nil
 }
 
-func (r Repository) Configure(authorName, authorEmail string) error {
-	configs := map[string]string{
-		"user.name":  authorName,
-		"user.email": authorEmail,
+// lock acquires the lock of the shared clone, if any.
+func (r Repository) lock() (func(), error) {
+	if r.cachedir == "" {
+		return func() {}, nil
 	}
 
-	for k, v := range configs {
-		err := r.run("config", k, v)
+	return lockFile(fmt.Sprintf("%s.lock", r.cachedir))
+}
+
+// SkipSubmodules avoids checking out submodules, which are not needed when only
+// the release metadata of the repository is used (e.g. finding versions).
+func (r *Repository) SkipSubmodules() {
+	r.skipSubmodules = true
+}
+
+func (r Repository) updateSubmodules() error {
+	if r.skipSubmodules {
+		return nil
+	} else if r.localdir != "" {
+		// avoids initializing submodules since that persists their URLs to the
+		// config shared with the checkout
+		err := r.config.Retry.Do(func() error {
+			return r.run("-c", "submodule.active=.", "submodule", "--quiet", "update", "--recursive")
+		})
 		if err != nil {
-			return errors.Wrapf(err, "setting %s", k)
+			return errors.Wrap(err, "updating submodules")
 		}
+
+		return nil
 	}
 
+	err := r.run("submodule", "--quiet", "sync", "--recursive")
+	if err != nil {
+		return errors.Wrap(err, "syncing submodules")
+	}
+
+	err = r.config.Retry.Do(func() error {
+		return r.run("submodule", "--quiet", "update", "--init", "--recursive")
+	})
+	if err != nil {
+		return errors.Wrap(err, "updating submodules")
+	}
+
+	return nil
+}
+
+// Configure sets the author of commits. The author is not persisted to the
+// git config since it is shared with other worktrees.
+func (r *Repository) Configure(authorName, authorEmail string) error {
+	r.authorName = authorName
+	r.authorEmail = authorEmail
+
 	return nil
 }
 
-func (r Repository) Commit(message string, rebase bool) (string, error) {
+// Tag is pushed along with a commit.
+type Tag struct {
+	Name string
+
+	// Commit is the commitish which the tag points to.
+	Commit string
+
+	// Message is the annotation of the tag. A lightweight tag is created if
+	// empty.
+	Message string
+}
+
+// TagFunc returns the tags to push with the commits which will be pushed,
+// oldest first. It may be called again with different commits if the commits
+// are rebased.
+type TagFunc func(commits []string) ([]Tag, error)
+
+// Commit commits all changes locally and returns the commit. Commits are only
+// pushed by Push.
+func (r Repository) Commit(message string) (string, error) {
+	if r.localdir != "" {
+		return "", errors.New("committing to a read-only repository is not supported")
+	}
+
 	err := r.run("add", "-A", ".")
 	if err != nil {
 		return "", errors.Wrap(err, "adding files")
@@ -104,76 +454,170 @@ func (r Repository) Commit(message string, rebase bool) (string, error) {
 		return "", errors.Wrap(err, "committing")
 	}
 
-	attempts := 0
-	if rebase {
-		attempts = 3
+	return r.ResolveCommit("HEAD")
+}
+
+// Push atomically pushes all local commits and their tags, if any, so the
+// branch is never updated without the tags. If a tag already exists remotely,
+// it must point to the same commit, in which case it is not pushed again. The
+// pushed commits are returned, oldest first.
+func (r Repository) Push(rebase bool, tagFn TagFunc) ([]string, error) {
+	if r.localdir != "" {
+		return nil, errors.New("pushing a read-only repository is not supported")
 	}
 
-	var finalError error
+	policy := r.config.Retry
 
-	for true {
-		err := r.run("push", "origin", fmt.Sprintf("HEAD:%s", r.branch))
-		if err == nil {
-			break
+	for attempt := 1; ; attempt++ {
+		commits, err := r.unpushedCommits()
+		if err != nil {
+			return nil, err
 		}
 
-		if attempts <= 0 {
-			finalError = err
+		refspecs := []string{fmt.Sprintf("HEAD:refs/heads/%s", r.branch)}
+
+		if tagFn != nil {
+			tags, err := tagFn(commits)
+			if err != nil {
+				return nil, errors.Wrap(err, "preparing tags")
+			}
+
+			for _, tag := range tags {
+				push, err := r.prepareTag(tag)
+				if err != nil {
+					return nil, err
+				} else if push {
+					refspecs = append(refspecs, fmt.Sprintf("refs/tags/%s", tag.Name))
+				}
+			}
+		}
 
-			break
+		err = policy.Do(func() error {
+			return r.run(append([]string{"push", "--atomic", "origin"}, refspecs...)...)
+		})
+		if err == nil {
+			return commits, nil
+		} else if !rebase || attempt > rebaseAttempts || IsTransient(err) {
+			return nil, errors.Wrap(err, "pushing")
 		}
 
-		time.Sleep(5 * time.Second)
+		// the push was rejected, likely due to new commits on the branch
+		time.Sleep(policy.Backoff(attempt))
 
-		err = r.run("pull", "--rebase", r.repository, r.branch)
+		err = r.rebase()
 		if err != nil {
-			return "", errors.Wrap(err, "rebasing")
+			return nil, errors.Wrap(err, "rebasing")
 		}
+	}
+}
 
-		err = r.run("commit", "--amend", "--reset-author", "--no-edit")
-		if err != nil {
-			return "", errors.Wrap(err, "resetting commit")
-		}
+// rebaseAttempts is how many times a rejected push is rebased onto the latest
+// remote branch and pushed again, independent of the network retry policy.
+const rebaseAttempts = 3
 
-		attempts--
+// rebase replays the local commits onto the latest remote branch, resetting
+// their dates as if they were just committed.
+func (r Repository) rebase() error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
 	}
 
-	if finalError != nil {
-		return "", finalError
+	err = r.config.Retry.Do(func() error {
+		return r.run("fetch", "--quiet", "--force", r.repository, fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", r.branch, r.branch))
+	})
+
+	unlock()
+
+	if err != nil {
+		return errors.Wrap(err, "fetching")
 	}
 
-	stdout := bytes.NewBuffer(nil)
+	return r.run("rebase", "--quiet", "--ignore-date", fmt.Sprintf("refs/remotes/origin/%s", r.branch))
+}
 
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	cmd.Dir = r.tmpdir
-	cmd.Stdout = stdout
-	cmd.Stderr = os.Stderr
+// unpushedCommits returns the local commits which are not yet on the remote
+// branch, oldest first.
+func (r Repository) unpushedCommits() ([]string, error) {
+	stdout := &bytes.Buffer{}
 
-	err = cmd.Run()
+	err := r.runRaw(stdout, "rev-list", "--reverse", "HEAD", "--not", fmt.Sprintf("refs/remotes/origin/%s", r.branch))
 	if err != nil {
-		return "", errors.Wrap(err, "resolving HEAD")
+		return nil, errors.Wrap(err, "listing unpushed commits")
 	}
 
-	return strings.TrimSpace(stdout.String()), nil
+	return strings.Fields(stdout.String()), nil
 }
 
-func (r Repository) Tag(commit, tag, message string) error {
-	err := r.run("tag", "-a", "-m", message, tag, commit)
+// prepareTag creates the tag locally, unless it already exists remotely, and
+// returns whether it needs to be pushed. An error is returned if the remote tag
+// points to a different commit.
+func (r Repository) prepareTag(tag Tag) (bool, error) {
+	commit, err := r.ResolveCommit(tag.Commit)
 	if err != nil {
-		return errors.Wrap(err, "tagging")
+		return false, errors.Wrap(err, "resolving tag commit")
 	}
 
-	err = r.run("push", "origin", tag)
+	remoteCommit, err := r.remoteTagCommit(tag.Name)
 	if err != nil {
-		return errors.Wrap(err, "pushing tag")
+		return false, errors.Wrap(err, "checking remote tag")
+	} else if remoteCommit == commit {
+		return false, nil
+	} else if remoteCommit != "" {
+		return false, fmt.Errorf("tag conflict: %s already exists and points to %s rather than %s", tag.Name, remoteCommit, commit)
 	}
 
-	return nil
+	// replaces any local tag which was never pushed (e.g. a previous attempt)
+	args := []string{"tag", "--force"}
+
+	if tag.Message != "" {
+		args = append(args, "-a", "-m", tag.Message)
+	}
+
+	err = r.run(append(args, tag.Name, commit)...)
+	if err != nil {
+		return false, errors.Wrap(err, "tagging")
+	}
+
+	return true, nil
+}
+
+// remoteTagCommit returns the commit which a remote tag points to, or an empty
+// string if the tag does not exist.
+func (r Repository) remoteTagCommit(name string) (string, error) {
+	stdout := &bytes.Buffer{}
+	ref := fmt.Sprintf("refs/tags/%s", name)
+
+	err := r.config.Retry.Do(func() error {
+		stdout.Reset()
+
+		return r.runRaw(stdout, "ls-remote", "origin", ref, fmt.Sprintf("%s^{}", ref))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var commit string
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		} else if fields[1] == fmt.Sprintf("%s^{}", ref) {
+			// the commit of an annotated tag
+			return fields[0], nil
+		} else if fields[1] == ref {
+			commit = fields[0]
+		}
+	}
+
+	return commit, nil
 }
 
 type Commit struct {
-	Commit     string
-	CommitDate time.Time
+	Commit     string    `json:"commit"`
+	CommitDate time.Time `json:"commit_date"`
+	Subject    string    `json:"subject,omitempty"`
 }
 
 func (r Repository) GetCommitList(since string) ([]Commit, error) {
@@ -213,6 +657,109 @@ func (r Repository) GetCommitList(since string) ([]Commit, error) {
 	return commits, nil
 }
 
+// GetCommitRange returns the first-parent commits reachable from to, but not
+// from, oldest first. All ancestors of to are returned if from is empty.
+func (r Repository) GetCommitRange(from, to string) ([]Commit, error) {
+	stdout := &bytes.Buffer{}
+	revrange := to
+
+	if from != "" {
+		revrange = fmt.Sprintf("%s..%s", from, to)
+	}
+
+	err := r.runRaw(stdout, "log", "--first-parent", "--format=%h%x09%ci%x09%s", "--reverse", revrange)
+	if err != nil {
+		return nil, errors.Wrap(err, "running git log")
+	}
+
+	var commits []Commit
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		lineSplit := strings.SplitN(line, "\t", 3)
+		if len(lineSplit) != 3 {
+			return nil, fmt.Errorf("unexpected git log output: %s", line)
+		}
+
+		commitDate, err := time.Parse("2006-01-02 15:04:05 -0700", lineSplit[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing commit date of commit %s", lineSplit[0])
+		}
+
+		commits = append(commits, Commit{
+			Commit:     lineSplit[0],
+			CommitDate: commitDate.UTC(),
+			Subject:    lineSplit[2],
+		})
+	}
+
+	return commits, nil
+}
+
+// HasCommit returns whether the commitish resolves to a known commit.
+func (r Repository) HasCommit(commitish string) bool {
+	return r.runRaw(ioutil.Discard, "rev-parse", "--quiet", "--verify", fmt.Sprintf("%s^{commit}", commitish)) == nil
+}
+
+// TagAnnotation returns the message of an annotated tag. Lightweight and
+// missing tags are not considered found.
+func (r Repository) TagAnnotation(tag string) (string, bool, error) {
+	stdout := &bytes.Buffer{}
+
+	err := r.runRaw(stdout, "for-each-ref", "--format=%(objecttype)%00%(contents)", fmt.Sprintf("refs/tags/%s", tag))
+	if err != nil {
+		return "", false, errors.Wrap(err, "running git for-each-ref")
+	}
+
+	split := strings.SplitN(stdout.String(), "\x00", 2)
+	if len(split) != 2 || split[0] != "tag" {
+		return "", false, nil
+	}
+
+	return strings.TrimSpace(split[1]), true, nil
+}
+
+// RemoteURLs returns the fetch URLs of all configured remotes.
+func (r Repository) RemoteURLs() ([]string, error) {
+	stdout := &bytes.Buffer{}
+
+	err := r.runRaw(stdout, "remote", "-v")
+	if err != nil {
+		return nil, errors.Wrap(err, "running git remote")
+	}
+
+	var urls []string
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[2] == "(fetch)" {
+			urls = append(urls, fields[1])
+		}
+	}
+
+	return urls, nil
+}
+
+// HasFile returns whether the path exists at the commitish.
+func (r Repository) HasFile(commitish, path string) bool {
+	return r.runRaw(ioutil.Discard, "cat-file", "-e", fmt.Sprintf("%s:%s", commitish, path)) == nil
+}
+
+// ListDir returns the names of the entries of a directory at the commitish.
+func (r Repository) ListDir(commitish, dir string) ([]string, error) {
+	stdout := &bytes.Buffer{}
+
+	err := r.runRaw(stdout, "ls-tree", "--name-only", fmt.Sprintf("%s:%s", commitish, dir))
+	if err != nil {
+		return nil, errors.Wrap(err, "running git ls-tree")
+	}
+
+	return strings.Fields(stdout.String()), nil
+}
+
 func (r Repository) Show(commitish, path string) ([]byte, error) {
 	stdout := &bytes.Buffer{}
 
@@ -221,85 +768,165 @@ func (r Repository) Show(commitish, path string) ([]byte, error) {
 	return stdout.Bytes(), err
 }
 
-func (r Repository) Checkout(commitish string) error {
-	return r.run("checkout", commitish)
-}
+// IsDirty returns whether the working tree has staged, unstaged, or untracked
+// changes.
+func (r Repository) IsDirty() (bool, error) {
+	stdout := &bytes.Buffer{}
 
-func (r Repository) run(args ...string) error {
-	return r.runRaw(os.Stderr, args...)
+	err := r.runRaw(stdout, "status", "--porcelain")
+	if err != nil {
+		return false, errors.Wrap(err, "running git status")
+	}
+
+	return strings.TrimSpace(stdout.String()) != "", nil
 }
 
-func (r Repository) runRaw(stdout io.Writer, args ...string) error {
-	var executable = "git"
+// WorkingTreeDigest returns a SHA-1 digest of all staged, unstaged, and
+// untracked changes relative to HEAD.
+func (r Repository) WorkingTreeDigest() (string, error) {
+	digest := sha1.New()
 
-	if r.privateKey != "" && (args[0] == "clone" || args[0] == "pull" || args[0] == "push") {
-		privateKey, err := ioutil.TempFile("", "git-privateKey")
-		if err != nil {
-			return errors.Wrap(err, "tempfile for id_rsa")
-		}
+	err := r.runRaw(digest, "diff", "--binary", "--no-ext-diff", "HEAD")
+	if err != nil {
+		return "", errors.Wrap(err, "running git diff")
+	}
 
-		defer os.RemoveAll(privateKey.Name())
+	stdout := &bytes.Buffer{}
 
-		err = os.Chmod(privateKey.Name(), 0600)
-		if err != nil {
-			return errors.Wrap(err, "chmod git wrapper")
-		}
+	err = r.runRaw(stdout, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return "", errors.Wrap(err, "running git ls-files")
+	}
 
-		err = ioutil.WriteFile(privateKey.Name(), []byte(r.privateKey), 0600)
-		if err != nil {
-			return errors.Wrap(err, "writing id_rsa")
+	untracked := strings.Split(strings.TrimSuffix(stdout.String(), "\x00"), "\x00")
+	sort.Strings(untracked)
+
+	for _, file := range untracked {
+		if file == "" {
+			continue
 		}
 
-		executableWrapper, err := ioutil.TempFile("", "git-executable")
+		fmt.Fprintf(digest, "untracked %s\x00", file)
+
+		err = func() error {
+			fh, err := os.Open(path.Join(r.tmpdir, file))
+			if err != nil {
+				return err
+			}
+
+			defer fh.Close()
+
+			_, err = io.Copy(digest, fh)
+
+			return err
+		}()
 		if err != nil {
-			return errors.Wrap(err, "tempfile for git wrapper")
+			return "", errors.Wrapf(err, "reading %s", file)
 		}
+	}
 
-		defer os.RemoveAll(executableWrapper.Name())
+	return fmt.Sprintf("%x", digest.Sum(nil)), nil
+}
 
-		_, err = executableWrapper.WriteString(fmt.Sprintf(`#!/bin/bash
+// ResolveCommit returns the full commit hash of a commitish.
+func (r Repository) ResolveCommit(commitish string) (string, error) {
+	stdout := &bytes.Buffer{}
 
-set -eu
+	err := r.runRaw(stdout, "rev-parse", "--verify", fmt.Sprintf("%s^{commit}", commitish))
+	if err != nil {
+		return "", errors.Wrapf(err, "resolving %s", commitish)
+	}
 
-mkdir -p ~/.ssh
+	return strings.TrimSpace(stdout.String()), nil
+}
 
-cat > ~/.ssh/config <<EOF
-StrictHostKeyChecking no
-LogLevel quiet
-EOF
+func (r Repository) Checkout(commitish string) error {
+	err := r.run("checkout", "--quiet", commitish)
+	if err != nil {
+		return err
+	}
 
-chmod 0600 ~/.ssh/config
+	unlock, err := r.lock()
+	if err != nil {
+		return errors.Wrap(err, "locking repository cache")
+	}
 
-eval $(ssh-agent) > /dev/null
+	defer unlock()
 
-trap "kill $SSH_AGENT_PID" 0
+	return r.updateSubmodules()
+}
 
-SSH_ASKPASS=false DISPLAY= ssh-add "%s" 2>/dev/null # TODO suppresses real errors?
+func (r Repository) run(args ...string) error {
+	return r.runRaw(os.Stderr, args...)
+}
 
-exec git "$@"`, privateKey.Name()))
-		if err != nil {
-			return errors.Wrap(err, "writing git wrapper")
-		}
+func (r Repository) runRaw(stdout io.Writer, args ...string) error {
+	return r.runDir(r.tmpdir, stdout, args...)
+}
 
-		err = executableWrapper.Close()
+func (r Repository) runDir(dir string, stdout io.Writer, args ...string) error {
+	var command = args[0]
+
+	for idx := 0; idx+2 < len(args) && args[idx] == "-c"; idx += 2 {
+		command = args[idx+2]
+	}
+
+	var remote = command == "clone" || command == "fetch" || command == "ls-remote" || command == "pull" || command == "push" || command == "submodule"
+
+	env, cleanup, err := r.environ()
+	if err != nil {
+		return errors.Wrap(err, "preparing environment")
+	}
+
+	defer cleanup()
+
+	if credentials := r.credentials(); len(credentials) > 0 && remote {
+		credentialArgs, credentialEnv, credentialCleanup, err := r.credentialHelperConfig(credentials)
 		if err != nil {
-			return errors.Wrap(err, "closing tempfile")
+			return errors.Wrap(err, "preparing credentials")
 		}
 
-		err = os.Chmod(executableWrapper.Name(), 0500)
+		defer credentialCleanup()
+
+		args = append(credentialArgs, args...)
+		env = append(env, credentialEnv...)
+	}
+
+	if (r.config.PrivateKey != "" || r.config.KnownHosts != "" || len(r.config.SubmoduleKeys) > 0) && remote {
+		sshCommand, sshCleanup, err := r.sshCommand()
 		if err != nil {
-			return errors.Wrap(err, "chmod git wrapper")
+			return errors.Wrap(err, "preparing ssh")
 		}
 
-		executable = executableWrapper.Name()
+		defer sshCleanup()
+
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=%s", sshCommand))
+	}
+
+	if r.authorName != "" || r.authorEmail != "" {
+		env = append(
+			env,
+			fmt.Sprintf("GIT_AUTHOR_NAME=%s", r.authorName),
+			fmt.Sprintf("GIT_AUTHOR_EMAIL=%s", r.authorEmail),
+			fmt.Sprintf("GIT_COMMITTER_NAME=%s", r.authorName),
+			fmt.Sprintf("GIT_COMMITTER_EMAIL=%s", r.authorEmail),
+		)
 	}
 
-	// fmt.Fprintf(os.Stderr, "> %s %s\n", executable, strings.Join(args, " "))
+	// fmt.Fprintf(os.Stderr, "> git %s\n", strings.Join(args, " "))
 
-	cmd := exec.Command(executable, args...)
-	cmd.Dir = r.tmpdir
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
 	cmd.Stdout = stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	err = cmd.Run()
+	if err != nil && remote {
+		return commandError{err: wrapSSHError(err, stderr.String()), output: stderr.String()}
+	}
 
-	return cmd.Run()
+	return err
 }
@@ -0,0 +1,874 @@
+package boshrelease_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+)
+
+var _ = Describe("Repository", func() {
+	Describe("HTTPS credentials", func() {
+		var serverdir string
+		var server *httptest.Server
+		var subject *Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote("repo.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			server, err = testing.StartGitHTTPServer(serverdir, "fake-user", "fake-password")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		It("authenticates with a username and password", func() {
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{
+				Username: "fake-user",
+				Password: "fake-password",
+			})
+
+			Expect(subject.Pull()).To(Succeed())
+
+			By("not persisting credentials", func() {
+				configBytes, err := ioutil.ReadFile(filepath.Join(subject.CachePath(), "config"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(configBytes)).NotTo(ContainSubstring("fake-password"))
+				Expect(string(configBytes)).NotTo(ContainSubstring("fake-user"))
+			})
+
+			By("authenticating subsequent pulls", func() {
+				Expect(subject.Pull()).To(Succeed())
+			})
+		})
+
+		It("fails with invalid credentials", func() {
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{
+				Username: "fake-user",
+				Password: "wrong-password",
+			})
+
+			Expect(subject.Pull()).NotTo(Succeed())
+		})
+
+		It("passes special characters of passwords through unchanged", func() {
+			server.Close()
+
+			password := `fake\npass$HOME` + "`id`'\""
+
+			var err error
+
+			server, err = testing.StartGitHTTPServer(serverdir, "fake-user", password)
+			Expect(err).NotTo(HaveOccurred())
+
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{
+				Username: "fake-user",
+				Password: password,
+			})
+
+			Expect(subject.Pull()).To(Succeed())
+		})
+
+		It("fails without credentials rather than prompting", func() {
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{})
+
+			Expect(subject.Pull()).NotTo(Succeed())
+		})
+	})
+
+	Describe("SSH remotes", func() {
+		var bindir, homedir string
+		var originalPath, originalHome string
+		var subject *Repository
+
+		generateKey := func(name, passphrase string) (string, string) {
+			err := testing.RunCommands(bindir, []string{fmt.Sprintf("ssh-keygen -q -t ed25519 -C %s -N '%s' -f %s", name, passphrase, name)})
+			Expect(err).NotTo(HaveOccurred())
+
+			privateKey, err := ioutil.ReadFile(filepath.Join(bindir, name))
+			Expect(err).NotTo(HaveOccurred())
+
+			publicKey, err := ioutil.ReadFile(filepath.Join(bindir, fmt.Sprintf("%s.pub", name)))
+			Expect(err).NotTo(HaveOccurred())
+
+			return string(privateKey), strings.Fields(string(publicKey))[1]
+		}
+
+		readRecorded := func(name string) string {
+			bytes, err := ioutil.ReadFile(filepath.Join(bindir, name))
+			Expect(err).NotTo(HaveOccurred())
+
+			return string(bytes)
+		}
+
+		BeforeEach(func() {
+			var err error
+
+			bindir, err = ioutil.TempDir("", "bosh-release-resource-fake-ssh")
+			Expect(err).NotTo(HaveOccurred())
+
+			homedir, err = ioutil.TempDir("", "bosh-release-resource-home")
+			Expect(err).NotTo(HaveOccurred())
+
+			// record the ssh invocation and simulate a changed host key
+			err = ioutil.WriteFile(filepath.Join(bindir, "ssh"), []byte(fmt.Sprintf(`#!/bin/bash
+for arg in "$@" ; do
+  case "$arg" in
+    UserKnownHostsFile=*) cat "${arg#UserKnownHostsFile=}" > %s/known_hosts ;;
+  esac
+done
+echo "$@" > %s/args
+ssh-add -L > %s/agent || true
+echo "@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@" >&2
+echo "@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @" >&2
+echo "Host key verification failed." >&2
+exit 255
+`, bindir, bindir, bindir)), 0755)
+			Expect(err).NotTo(HaveOccurred())
+
+			originalPath = os.Getenv("PATH")
+			originalHome = os.Getenv("HOME")
+
+			os.Setenv("PATH", fmt.Sprintf("%s:%s", bindir, originalPath))
+			os.Setenv("HOME", homedir)
+		})
+
+		AfterEach(func() {
+			os.Setenv("PATH", originalPath)
+			os.Setenv("HOME", originalHome)
+
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(bindir)).To(Succeed())
+			Expect(os.RemoveAll(homedir)).To(Succeed())
+		})
+
+		It("strictly verifies host keys with the configured known_hosts", func() {
+			privateKey, _ := generateKey("main", "")
+
+			subject = NewRepository("git@git.example.com:fake/release.git", "master", RepositoryConfig{
+				PrivateKey: privateKey,
+				KnownHosts: "git.example.com ssh-ed25519 AAAAfake",
+			})
+
+			err := subject.Pull()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ssh host key mismatch"))
+
+			Expect(readRecorded("args")).To(ContainSubstring("StrictHostKeyChecking=yes"))
+			Expect(readRecorded("args")).To(ContainSubstring("git@git.example.com"))
+			Expect(readRecorded("known_hosts")).To(Equal("git.example.com ssh-ed25519 AAAAfake"))
+
+			By("not modifying the user's ssh config", func() {
+				_, err := os.Stat(filepath.Join(homedir, ".ssh"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		It("supports private key passphrases", func() {
+			privateKey, publicKey := generateKey("main", "fake-passphrase")
+
+			subject = NewRepository("git@git.example.com:fake/release.git", "master", RepositoryConfig{
+				PrivateKey:           privateKey,
+				PrivateKeyPassphrase: "fake-passphrase",
+			})
+
+			Expect(subject.Pull()).NotTo(Succeed())
+			Expect(readRecorded("agent")).To(ContainSubstring(publicKey))
+		})
+
+		It("surfaces errors from incorrect passphrases", func() {
+			privateKey, _ := generateKey("main", "fake-passphrase")
+
+			subject = NewRepository("git@git.example.com:fake/release.git", "master", RepositoryConfig{
+				PrivateKey:           privateKey,
+				PrivateKeyPassphrase: "wrong-passphrase",
+			})
+
+			err := subject.Pull()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("adding private key"))
+
+			_, err = os.Stat(filepath.Join(bindir, "args"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("uses submodule keys for matching remotes", func() {
+			mainPrivateKey, mainPublicKey := generateKey("main", "")
+			submodulePrivateKey, submodulePublicKey := generateKey("submodule", "")
+
+			config := RepositoryConfig{
+				PrivateKey: mainPrivateKey,
+				SubmoduleKeys: []SSHKey{
+					{
+						URI:        "git@git.example.com:fake/submodule.git",
+						PrivateKey: submodulePrivateKey,
+					},
+				},
+			}
+
+			subject = NewRepository("ssh://git@git.example.com/fake/submodule", "master", config)
+			Expect(subject.Pull()).NotTo(Succeed())
+			Expect(readRecorded("agent")).To(ContainSubstring(submodulePublicKey))
+			Expect(readRecorded("agent")).NotTo(ContainSubstring(mainPublicKey))
+			Expect(subject.Close()).To(Succeed())
+			Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+
+			subject = NewRepository("git@git.example.com:fake/release.git", "master", config)
+			Expect(subject.Pull()).NotTo(Succeed())
+			Expect(readRecorded("agent")).To(ContainSubstring(mainPublicKey))
+			Expect(readRecorded("agent")).NotTo(ContainSubstring(submodulePublicKey))
+		})
+	})
+
+	Describe("network configuration", func() {
+		var serverdir string
+		var handler http.Handler
+		var subject *Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote("repo.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			handler, err = testing.NewGitHTTPHandler(serverdir, "", "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		It("trusts configured CA certificates", func() {
+			server := httptest.NewTLSServer(handler)
+			defer server.Close()
+
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{})
+			Expect(subject.Pull()).NotTo(Succeed())
+
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{
+				CACerts: []string{
+					string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+				},
+			})
+			Expect(subject.Pull()).To(Succeed())
+		})
+
+		It("uses the configured proxy", func() {
+			var proxied int32
+
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&proxied, 1)
+				handler.ServeHTTP(w, r)
+			}))
+			defer proxy.Close()
+
+			subject = NewRepository("http://git.example.invalid/repo.git", "master", RepositoryConfig{
+				Proxy: proxy.URL,
+			})
+			Expect(subject.Pull()).To(Succeed())
+			Expect(atomic.LoadInt32(&proxied)).To(BeNumerically(">", 0))
+		})
+
+		It("applies git config overrides", func() {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			subject = NewRepository("https://git.example.invalid/repo.git", "master", RepositoryConfig{
+				GitConfig: map[string]string{
+					fmt.Sprintf("url.%s/.insteadOf", server.URL): "https://git.example.invalid/",
+				},
+			})
+			Expect(subject.Pull()).To(Succeed())
+		})
+	})
+
+	Describe("submodules", func() {
+		var serverdir string
+		var server *httptest.Server
+		var subject *Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote(
+				"sub.git",
+				"mv work sub-work",
+				"git init --bare repo.git",
+				"git clone repo.git work",
+				"cd work && git -c protocol.file.allow=always submodule --quiet add ../sub.git sub && git config -f .gitmodules submodule.sub.url https://github.example.invalid/org/sub.git && git add .gitmodules sub && git -c user.name=test -c user.email=test@localhost commit -m init && git push origin HEAD:master",
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			server, err = testing.StartGitHTTPServer(serverdir, "fake-user", "fake-password")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		It("rewrites submodule URLs with per-host credentials and updates them on subsequent pulls", func() {
+			subject = NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{
+				URLRewrites: []URLRewrite{
+					{
+						URL:       fmt.Sprintf("%s/", server.URL),
+						InsteadOf: "https://github.example.invalid/org/",
+					},
+				},
+				Credentials: []Credential{
+					{
+						Host:     strings.TrimPrefix(server.URL, "http://"),
+						Username: "fake-user",
+						Password: "fake-password",
+					},
+				},
+			})
+
+			Expect(subject.Pull()).To(Succeed())
+
+			contents, err := ioutil.ReadFile(filepath.Join(subject.Path(), "sub", "file"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("one\n"))
+
+			err = testing.RunCommands(
+				serverdir,
+				[]string{
+					"cd sub-work && echo two > file && git -c user.name=test -c user.email=test@localhost commit -am two && git push origin HEAD:master",
+					"cd work/sub && git -c protocol.file.allow=always pull --quiet origin master",
+					"cd work && git add sub && git -c user.name=test -c user.email=test@localhost commit -m bump && git push origin HEAD:master",
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(subject.Pull()).To(Succeed())
+
+			contents, err = ioutil.ReadFile(filepath.Join(subject.Path(), "sub", "file"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("two\n"))
+		})
+
+		It("skips submodules when requested", func() {
+			subject = NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{})
+			subject.SkipSubmodules()
+
+			Expect(subject.Pull()).To(Succeed())
+
+			_, err := os.Stat(filepath.Join(subject.Path(), "sub", "file"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("fails without credentials for the submodule host", func() {
+			subject = NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{
+				URLRewrites: []URLRewrite{
+					{
+						URL:       fmt.Sprintf("%s/", server.URL),
+						InsteadOf: "https://github.example.invalid/org/",
+					},
+				},
+				Credentials: []Credential{
+					{
+						Host:     "git.example.invalid",
+						Username: "fake-user",
+						Password: "fake-password",
+					},
+				},
+			})
+
+			Expect(subject.Pull()).NotTo(Succeed())
+		})
+
+		It("does not send the repository credentials of a non-HTTP remote to the submodule host", func() {
+			subject = NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{
+				Username: "fake-user",
+				Password: "fake-password",
+				URLRewrites: []URLRewrite{
+					{
+						URL:       fmt.Sprintf("%s/", server.URL),
+						InsteadOf: "https://github.example.invalid/org/",
+					},
+				},
+			})
+
+			Expect(subject.Pull()).NotTo(Succeed())
+		})
+	})
+
+	Describe("worktrees", func() {
+		var serverdir string
+		var subjects []*Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote("repo.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			subjects = nil
+		})
+
+		AfterEach(func() {
+			for _, subject := range subjects {
+				Expect(subject.Close()).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(subjects[0].CachePath())).To(Succeed())
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		newSubject := func() *Repository {
+			subject := NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{})
+			subjects = append(subjects, subject)
+
+			return subject
+		}
+
+		It("uses a separate worktree of a shared clone", func() {
+			first := newSubject()
+			second := newSubject()
+
+			Expect(first.CachePath()).To(Equal(second.CachePath()))
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+
+			for idx, subject := range []*Repository{first, second} {
+				wg.Add(1)
+
+				go func(idx int, subject *Repository) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					errs[idx] = subject.Pull()
+				}(idx, subject)
+			}
+
+			wg.Wait()
+
+			Expect(errs[0]).NotTo(HaveOccurred())
+			Expect(errs[1]).NotTo(HaveOccurred())
+			Expect(first.Path()).NotTo(Equal(second.Path()))
+			Expect(first.Path()).NotTo(Equal(first.CachePath()))
+
+			By("isolating checkouts", func() {
+				err := testing.RunCommands(
+					serverdir,
+					[]string{
+						"cd work && echo two > file && git -c user.name=test -c user.email=test@localhost commit -am two && git push origin HEAD:master",
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(first.Pull()).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(first.Path(), "file"), []byte("dirty\n"), 0644)).To(Succeed())
+
+				contents, err := ioutil.ReadFile(filepath.Join(second.Path(), "file"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("one\n"))
+
+				Expect(second.Checkout("HEAD")).To(Succeed())
+			})
+
+			By("checking out the latest commit for new repositories", func() {
+				third := newSubject()
+				Expect(third.Pull()).To(Succeed())
+
+				contents, err := ioutil.ReadFile(filepath.Join(third.Path(), "file"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("two\n"))
+			})
+
+			By("removing worktrees when closed", func() {
+				path := first.Path()
+
+				Expect(first.Close()).To(Succeed())
+
+				_, err := os.Stat(path)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		It("removes worktrees abandoned by previous processes", func() {
+			first := newSubject()
+			Expect(first.Pull()).To(Succeed())
+
+			abandoned, err := ioutil.TempDir("", "bosh-release-worktree-")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = testing.RunCommands(
+				first.Path(),
+				[]string{
+					fmt.Sprintf("git -C %s worktree add --quiet --detach %s $( git rev-parse HEAD )", first.CachePath(), abandoned),
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(newSubject().Pull()).To(Succeed())
+
+			_, err = os.Stat(abandoned)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			_, err = os.Stat(first.Path())
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("read-only repositories", func() {
+		var serverdir string
+		var subject *Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote(
+				"sub.git",
+				"cd work && echo sub > file && git -c user.name=test -c user.email=test@localhost commit -am sub && git push origin HEAD:master",
+				"rm -rf work && git init work",
+				"cd work && echo one > file && git -c protocol.file.allow=always submodule --quiet add ../sub.git sub && git add file && git -c user.name=test -c user.email=test@localhost commit -m one",
+				"cd work && git branch other && echo two > file && git -c user.name=test -c user.email=test@localhost commit -am two",
+				"git clone work checkout",
+				"cd checkout && echo uncommitted > file",
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		It("uses a separate worktree without modifying the checkout", func() {
+			checkout := filepath.Join(serverdir, "checkout")
+
+			configBefore, err := ioutil.ReadFile(filepath.Join(checkout, ".git", "config"))
+			Expect(err).NotTo(HaveOccurred())
+
+			subject = NewReadOnlyRepository(checkout, "", RepositoryConfig{
+				GitConfig: map[string]string{
+					"protocol.file.allow": "always",
+				},
+			})
+
+			Expect(subject.Pull()).To(Succeed())
+			Expect(subject.Path()).NotTo(Equal(checkout))
+
+			contents, err := ioutil.ReadFile(filepath.Join(subject.Path(), "file"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("two\n"))
+
+			contents, err = ioutil.ReadFile(filepath.Join(subject.Path(), "sub", "file"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("sub\n"))
+
+			_, err = subject.Commit("fake")
+			Expect(err).To(HaveOccurred())
+
+			Expect(subject.Close()).To(Succeed())
+
+			By("leaving the checkout unmodified", func() {
+				contents, err := ioutil.ReadFile(filepath.Join(checkout, "file"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("uncommitted\n"))
+
+				configAfter, err := ioutil.ReadFile(filepath.Join(checkout, ".git", "config"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(configAfter)).To(Equal(string(configBefore)))
+
+				worktrees, err := testing.RunCommandStdout(checkout, "git", "worktree", "list")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(strings.Split(strings.TrimSpace(worktrees), "\n")).To(HaveLen(1))
+			})
+		})
+
+		It("checks out the configured branch", func() {
+			subject = NewReadOnlyRepository(filepath.Join(serverdir, "work"), "other", RepositoryConfig{
+				GitConfig: map[string]string{
+					"protocol.file.allow": "always",
+				},
+			})
+
+			Expect(subject.Pull()).To(Succeed())
+
+			contents, err := ioutil.ReadFile(filepath.Join(subject.Path(), "file"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("one\n"))
+		})
+	})
+
+	Describe("mirrors", func() {
+		var serverdir string
+		var subject *Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote("mirror.git")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		It("falls back to mirrors in order", func() {
+			primary := filepath.Join(serverdir, "missing.git")
+			mirror := filepath.Join(serverdir, "mirror.git")
+
+			subject = NewRepository(primary, "master", RepositoryConfig{
+				Mirrors: []string{
+					filepath.Join(serverdir, "also-missing.git"),
+					mirror,
+				},
+			})
+
+			Expect(subject.Pull()).To(Succeed())
+			Expect(subject.Remote()).To(Equal(mirror))
+
+			contents, err := ioutil.ReadFile(filepath.Join(subject.Path(), "file"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("one\n"))
+
+			By("pushing to the repository rather than the mirror", func() {
+				origin, err := testing.RunCommandStdout(subject.CachePath(), "git", "config", "remote.origin.url")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(strings.TrimSpace(origin)).To(Equal(primary))
+			})
+
+			By("preferring the repository once available", func() {
+				err := testing.RunCommands(
+					serverdir,
+					[]string{
+						"git clone --quiet --bare mirror.git missing.git",
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(subject.Pull()).To(Succeed())
+				Expect(subject.Remote()).To(Equal(primary))
+			})
+		})
+
+		It("fails when every location fails", func() {
+			subject = NewRepository(filepath.Join(serverdir, "missing.git"), "master", RepositoryConfig{
+				Mirrors: []string{
+					filepath.Join(serverdir, "also-missing.git"),
+				},
+			})
+
+			err := subject.Pull()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("all mirrors failed"))
+			Expect(err.Error()).To(ContainSubstring("also-missing.git"))
+		})
+	})
+
+	Describe("Commit", func() {
+		var serverdir string
+		var subject *Repository
+		var sourceCommit string
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote("repo.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			sourceCommit, err = testing.RunCommandStdout(filepath.Join(serverdir, "work"), "git", "rev-parse", "HEAD")
+			Expect(err).NotTo(HaveOccurred())
+			sourceCommit = strings.TrimSpace(sourceCommit)
+
+			subject = NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{})
+			Expect(subject.Pull()).To(Succeed())
+			Expect(subject.Configure("test", "test@localhost")).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(subject.Path(), "file"), []byte("two\n"), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(subject.Close()).To(Succeed())
+			Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		remoteRef := func(ref string) string {
+			stdout, err := testing.RunCommandStdout(filepath.Join(serverdir, "repo.git"), "git", "rev-parse", "--verify", "--quiet", ref)
+			if err != nil {
+				return ""
+			}
+
+			return strings.TrimSpace(stdout)
+		}
+
+		tagFn := func(commits []string) ([]Tag, error) {
+			return []Tag{
+				{
+					Name:    "v1.0.0",
+					Commit:  sourceCommit,
+					Message: fmt.Sprintf("finalized in %s", commits[0]),
+				},
+			}, nil
+		}
+
+		commitAndPush := func(rebase bool) (string, error) {
+			_, err := subject.Commit("finalize")
+			if err != nil {
+				return "", err
+			}
+
+			commits, err := subject.Push(rebase, tagFn)
+			if err != nil {
+				return "", err
+			}
+
+			Expect(commits).To(HaveLen(1))
+
+			return commits[0], nil
+		}
+
+		It("pushes the commit and tag", func() {
+			commit, err := commitAndPush(false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remoteRef("refs/heads/master")).To(Equal(commit))
+			Expect(remoteRef("refs/tags/v1.0.0^{commit}")).To(Equal(sourceCommit))
+
+			message, err := testing.RunCommandStdout(filepath.Join(serverdir, "repo.git"), "git", "tag", "-l", "--format=%(contents)", "v1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(message)).To(Equal(fmt.Sprintf("finalized in %s", commit)))
+		})
+
+		It("accepts an existing tag of the same commit", func() {
+			err := testing.RunCommands(
+				filepath.Join(serverdir, "work"),
+				[]string{"git tag v1.0.0 HEAD && git push origin v1.0.0"},
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			commit, err := commitAndPush(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remoteRef("refs/heads/master")).To(Equal(commit))
+		})
+
+		It("pushes neither the commit nor the tag when the tag conflicts", func() {
+			err := testing.RunCommands(
+				filepath.Join(serverdir, "work"),
+				[]string{"git -c user.name=test -c user.email=test@localhost commit --allow-empty -m other && git -c user.name=test -c user.email=test@localhost tag -a -m other v1.0.0 HEAD && git push origin v1.0.0"},
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			conflictCommit := remoteRef("refs/tags/v1.0.0^{commit}")
+
+			_, err = commitAndPush(false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("tag conflict: v1.0.0 already exists and points to %s rather than %s", conflictCommit, sourceCommit)))
+
+			Expect(remoteRef("refs/heads/master")).To(Equal(sourceCommit))
+		})
+
+		It("pushes neither the commit nor the tag when either is rejected", func() {
+			err := testing.RunCommands(
+				filepath.Join(serverdir, "work"),
+				[]string{"git -c user.name=test -c user.email=test@localhost commit --allow-empty -m other && git push origin HEAD:master"},
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			upstream := remoteRef("refs/heads/master")
+
+			_, err = commitAndPush(false)
+			Expect(err).To(HaveOccurred())
+
+			Expect(remoteRef("refs/heads/master")).To(Equal(upstream))
+			Expect(remoteRef("refs/tags/v1.0.0")).To(BeEmpty())
+
+			By("rebasing when enabled", func() {
+				subject = NewRepository(filepath.Join(serverdir, "repo.git"), "master", RepositoryConfig{
+					Retry: RetryPolicy{Attempts: 2},
+				})
+				Expect(subject.Pull()).To(Succeed())
+				Expect(subject.Configure("test", "test@localhost")).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(subject.Path(), "file"), []byte("two\n"), 0644)).To(Succeed())
+
+				Expect(testing.RunCommands(
+					filepath.Join(serverdir, "work"),
+					[]string{"git -c user.name=test -c user.email=test@localhost commit --allow-empty -m another && git push origin HEAD:master"},
+				)).To(Succeed())
+
+				commit, err := commitAndPush(true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(remoteRef("refs/heads/master")).To(Equal(commit))
+				Expect(remoteRef("refs/tags/v1.0.0^{commit}")).To(Equal(sourceCommit))
+			})
+		})
+
+		It("pushes several commits with their tags", func() {
+			first, err := subject.Commit("first")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ioutil.WriteFile(filepath.Join(subject.Path(), "file"), []byte("three\n"), 0644)).To(Succeed())
+
+			second, err := subject.Commit("second")
+			Expect(err).NotTo(HaveOccurred())
+
+			commits, err := subject.Push(false, func(commits []string) ([]Tag, error) {
+				return []Tag{
+					{Name: "v1.0.0", Commit: commits[0]},
+					{Name: "v1.1.0", Commit: commits[1]},
+				}, nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commits).To(Equal([]string{first, second}))
+
+			Expect(remoteRef("refs/heads/master")).To(Equal(second))
+			Expect(remoteRef("refs/tags/v1.0.0")).To(Equal(first))
+			Expect(remoteRef("refs/tags/v1.1.0")).To(Equal(second))
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package boshrelease
+
+import (
+	"math/rand"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy retries operations which fail transiently (e.g. network errors)
+// with exponential backoff and jitter. Permanent failures (e.g. authentication
+// or missing refs) are never retried. A zero value does not retry.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, including the first.
+	Attempts int
+
+	// InitialBackoff is the delay before the first retry, which doubles for
+	// each subsequent retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:       4,
+	InitialBackoff: 2 * time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// permanentErrorPattern takes precedence over transientErrorPattern since some
+// messages include both (e.g. `unable to access '...': The requested URL
+// returned error: 403`).
+var permanentErrorPattern = regexp.MustCompile(`(?i)(\[rejected\]|non-fast-forward|atomic push failed|authentication failed|could not read (username|password)|permission denied|host key verification failed|repository not found|does not appear to be a git repository|couldn't find remote ref|invalid reference|not a valid object name|returned error: 40[134]|status code 40[134]|access denied|forbidden|unauthorized)`)
+
+var transientErrorPattern = regexp.MustCompile(`(?i)(could not resolve host|temporary failure in name resolution|connection (timed out|refused|reset|closed)|operation timed out|timeout|the remote end hung up unexpectedly|early eof|rpc failed|returned error: (408|429|5[0-9][0-9])|status code (408|429|5[0-9][0-9])|too many requests|tls handshake|gnutls_handshake|ssl_(read|write|connect)|broken pipe|unexpected eof|i/o timeout|no route to host|network is unreachable)`)
+
+// commandError retains the output of a failed command for classifying it.
+type commandError struct {
+	err    error
+	output string
+}
+
+func (e commandError) Error() string {
+	return e.err.Error()
+}
+
+// IsTransient returns whether err is a failure which may succeed if retried.
+func IsTransient(err error) bool {
+	cmdErr, ok := errors.Cause(err).(commandError)
+	if !ok {
+		return false
+	} else if permanentErrorPattern.MatchString(cmdErr.output) {
+		return false
+	}
+
+	return transientErrorPattern.MatchString(cmdErr.output)
+}
+
+// Backoff returns the delay before the retry following attempt (starting at
+// 1). The delay is randomized between half and all of the exponential backoff
+// to avoid synchronized retries.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// Do calls fn until it succeeds, fails permanently, or all attempts are used.
+func (p RetryPolicy) Do(fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || !IsTransient(err) {
+			return err
+		}
+
+		time.Sleep(p.Backoff(attempt))
+	}
+}
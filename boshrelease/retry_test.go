@@ -0,0 +1,161 @@
+package boshrelease_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	Describe("Backoff", func() {
+		It("grows exponentially with jitter up to the maximum", func() {
+			policy := RetryPolicy{
+				InitialBackoff: time.Second,
+				MaxBackoff:     5 * time.Second,
+			}
+
+			for i := 0; i < 20; i++ {
+				Expect(policy.Backoff(1)).To(BeNumerically("~", 750*time.Millisecond, 250*time.Millisecond))
+				Expect(policy.Backoff(2)).To(BeNumerically("~", 1500*time.Millisecond, 500*time.Millisecond))
+				Expect(policy.Backoff(3)).To(BeNumerically("~", 3*time.Second, time.Second))
+				Expect(policy.Backoff(10)).To(BeNumerically("~", 3750*time.Millisecond, 1250*time.Millisecond))
+			}
+		})
+	})
+
+	Describe("Do", func() {
+		It("does not retry errors which are not from commands", func() {
+			var calls int
+
+			err := RetryPolicy{Attempts: 3}.Do(func() error {
+				calls++
+
+				return errors.New("fake-err")
+			})
+			Expect(err).To(MatchError("fake-err"))
+			Expect(calls).To(Equal(1))
+		})
+	})
+
+	Describe("with repositories", func() {
+		var serverdir string
+		var handler http.Handler
+		var subject *Repository
+
+		BeforeEach(func() {
+			var err error
+
+			serverdir, err = testing.GenerateRemote("repo.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			handler, err = testing.NewGitHTTPHandler(serverdir, "fake-user", "fake-password")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if subject != nil {
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+			}
+
+			Expect(os.RemoveAll(serverdir)).To(Succeed())
+		})
+
+		failing := func(status, failures int32) (*httptest.Server, *int32) {
+			var requests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= failures {
+					w.WriteHeader(int(status))
+
+					return
+				}
+
+				handler.ServeHTTP(w, r)
+			}))
+
+			return server, &requests
+		}
+
+		pull := func(server *httptest.Server) error {
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{
+				Username: "fake-user",
+				Password: "fake-password",
+				Retry: RetryPolicy{
+					Attempts:       3,
+					InitialBackoff: time.Millisecond,
+				},
+			})
+
+			return subject.Pull()
+		}
+
+		It("retries transient failures", func() {
+			server, requests := failing(http.StatusServiceUnavailable, 2)
+			defer server.Close()
+
+			Expect(pull(server)).To(Succeed())
+			Expect(atomic.LoadInt32(requests)).To(BeNumerically(">", 2))
+		})
+
+		It("retries request timeouts and rate limits", func() {
+			for _, status := range []int32{http.StatusRequestTimeout, http.StatusTooManyRequests} {
+				server, requests := failing(status, 2)
+
+				Expect(pull(server)).To(Succeed(), fmt.Sprintf("status %d", status))
+				Expect(atomic.LoadInt32(requests)).To(BeNumerically(">", 2))
+
+				server.Close()
+				Expect(subject.Close()).To(Succeed())
+				Expect(os.RemoveAll(subject.CachePath())).To(Succeed())
+				subject = nil
+			}
+		})
+
+		It("does not retry missing repositories", func() {
+			server, requests := failing(http.StatusNotFound, 3)
+			defer server.Close()
+
+			err := pull(server)
+			Expect(err).To(HaveOccurred())
+			Expect(IsTransient(err)).To(BeFalse())
+			Expect(atomic.LoadInt32(requests)).To(Equal(int32(1)))
+		})
+
+		It("does not retry permanent failures", func() {
+			var requests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				handler.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			subject = NewRepository(fmt.Sprintf("%s/repo.git", server.URL), "master", RepositoryConfig{
+				Username: "fake-user",
+				Password: "wrong-password",
+				Retry: RetryPolicy{
+					Attempts:       3,
+					InitialBackoff: time.Millisecond,
+				},
+			})
+
+			err := subject.Pull()
+			Expect(err).To(HaveOccurred())
+			Expect(IsTransient(err)).To(BeFalse())
+
+			// the unauthenticated and authenticated requests of a single attempt
+			Expect(atomic.LoadInt32(&requests)).To(BeNumerically("<=", 2))
+		})
+	})
+})
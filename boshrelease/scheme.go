@@ -0,0 +1,413 @@
+package boshrelease
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+// Version is a final version parsed by a VersionScheme.
+type Version interface {
+	// Original returns the version as it was parsed.
+	Original() string
+
+	// Compare returns -1, 0, or 1 if the version is less than, equal to, or
+	// greater than another version of the same scheme.
+	Compare(other Version) int
+
+	// Prerelease returns the prerelease of the version, if any.
+	Prerelease() string
+
+	// Release returns the version without its prerelease.
+	Release() Version
+
+	// Segments returns the numeric components of the version, most
+	// significant first (e.g. major, minor, and patch).
+	Segments() []int64
+}
+
+// VersionConstraint matches versions of the scheme which parsed it.
+type VersionConstraint interface {
+	Check(version Version) bool
+}
+
+// VersionScheme parses, orders, and constrains versions of a release.
+type VersionScheme interface {
+	Parse(version string) (Version, error)
+	ParseConstraint(constraint string) (VersionConstraint, error)
+}
+
+// ParseVersionScheme returns the scheme by name: semver (the default), dotted,
+// or calver.
+func ParseVersionScheme(name string) (VersionScheme, error) {
+	switch name {
+	case "", "semver":
+		return SemverScheme{}, nil
+	case "dotted":
+		return DottedScheme{}, nil
+	case "calver":
+		return CalVerScheme{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported version scheme: %s (expected semver, dotted, or calver)", name)
+}
+
+// SemverScheme uses semantic versioning, where versions with fewer than three
+// components are treated as zero-padded (e.g. 264.7 is 264.7.0), and
+// constraints use the Masterminds/semver syntax.
+type SemverScheme struct{}
+
+func (SemverScheme) Parse(version string) (Version, error) {
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return semverVersion{parsed}, nil
+}
+
+func (SemverScheme) ParseConstraint(constraint string) (VersionConstraint, error) {
+	parsed, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	return semverConstraint{parsed}, nil
+}
+
+type semverVersion struct {
+	*semver.Version
+}
+
+func (v semverVersion) Compare(other Version) int {
+	if o, ok := other.(semverVersion); ok {
+		return v.Version.Compare(o.Version)
+	}
+
+	return strings.Compare(v.Original(), other.Original())
+}
+
+func (v semverVersion) Release() Version {
+	release, err := v.Version.SetPrerelease("")
+	if err != nil {
+		return v
+	}
+
+	return semverVersion{&release}
+}
+
+func (v semverVersion) Segments() []int64 {
+	return []int64{v.Major(), v.Minor(), v.Patch()}
+}
+
+type semverConstraint struct {
+	*semver.Constraints
+}
+
+func (c semverConstraint) Check(version Version) bool {
+	if v, ok := version.(semverVersion); ok {
+		return c.Constraints.Check(v.Version)
+	}
+
+	return false
+}
+
+var dottedVersionPattern = regexp.MustCompile(`^v?([0-9]+(?:\.[0-9]+)*)(?:-([0-9A-Za-z.-]+))?$`)
+
+// DottedScheme allows any number of dot-separated integers (e.g. 264.7 or
+// 1.2.3.4), optionally with a `v` prefix and a `-prerelease` suffix. Versions
+// are ordered numerically by component, with missing components treated as
+// zero. Constraints are comma-separated (all must match) comparisons using =,
+// !=, >, >=, <, or <=, where an `x` or `*` component matches anything (e.g.
+// `264.x`, `>=1.2, <2`); alternatives are separated by `||`.
+type DottedScheme struct{}
+
+func (DottedScheme) Parse(version string) (Version, error) {
+	return parseAnyDottedVersion(version)
+}
+
+func (s DottedScheme) ParseConstraint(constraint string) (VersionConstraint, error) {
+	return parseDottedConstraint(constraint, s.Parse)
+}
+
+// CalVerScheme is the dotted scheme for calendar versions whose first
+// component is a year (YY or YYYY) and whose optional second component is a
+// month (e.g. 2024.06, 24.04.1, or 2024.06.13.2). Versions are ordered
+// chronologically, followed by any remaining components.
+type CalVerScheme struct{}
+
+func (CalVerScheme) Parse(version string) (Version, error) {
+	parsed, err := parseDottedVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if year := parsed.segments[0]; !(year >= 1970 && year <= 9999) && !(year <= 99 && len(parsed.raw[0]) == 2) {
+		return nil, fmt.Errorf("invalid calendar version: %s: year expected", version)
+	} else if len(parsed.segments) > 1 && (parsed.segments[1] < 1 || parsed.segments[1] > 12) {
+		return nil, fmt.Errorf("invalid calendar version: %s: month expected", version)
+	}
+
+	return parsed, nil
+}
+
+func (s CalVerScheme) ParseConstraint(constraint string) (VersionConstraint, error) {
+	return parseDottedConstraint(constraint, s.Parse)
+}
+
+type dottedVersion struct {
+	original   string
+	raw        []string
+	segments   []int64
+	prerelease string
+}
+
+func parseDottedVersion(version string) (dottedVersion, error) {
+	v, err := parseAnyDottedVersion(version)
+	if err != nil {
+		return dottedVersion{}, err
+	}
+
+	return v.(dottedVersion), nil
+}
+
+// parseAnyDottedVersion parses a dotted version without any validation
+// specific to a scheme.
+func parseAnyDottedVersion(version string) (Version, error) {
+	match := dottedVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return nil, fmt.Errorf("invalid dotted version: %s", version)
+	}
+
+	parsed := dottedVersion{
+		original:   version,
+		raw:        strings.Split(match[1], "."),
+		prerelease: match[2],
+	}
+
+	for _, segment := range parsed.raw {
+		value, err := strconv.ParseInt(segment, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid dotted version: %s", version)
+		}
+
+		parsed.segments = append(parsed.segments, value)
+	}
+
+	return parsed, nil
+}
+
+func (v dottedVersion) Original() string {
+	return v.original
+}
+
+func (v dottedVersion) Prerelease() string {
+	return v.prerelease
+}
+
+func (v dottedVersion) Release() Version {
+	v.prerelease = ""
+
+	return v
+}
+
+func (v dottedVersion) Segments() []int64 {
+	return v.segments
+}
+
+func (v dottedVersion) Compare(other Version) int {
+	o, ok := other.(dottedVersion)
+	if !ok {
+		return strings.Compare(v.Original(), other.Original())
+	}
+
+	for idx := 0; idx < len(v.segments) || idx < len(o.segments); idx++ {
+		var a, b int64
+
+		if idx < len(v.segments) {
+			a = v.segments[idx]
+		}
+
+		if idx < len(o.segments) {
+			b = o.segments[idx]
+		}
+
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	}
+
+	return comparePrerelease(v.prerelease, o.prerelease)
+}
+
+// comparePrerelease orders prereleases like semver: a version without a
+// prerelease is greater, numeric identifiers are compared numerically, and
+// identifiers are otherwise compared lexically.
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	} else if a == "" {
+		return 1
+	} else if b == "" {
+		return -1
+	}
+
+	aSplit := strings.Split(a, ".")
+	bSplit := strings.Split(b, ".")
+
+	for idx := 0; idx < len(aSplit) && idx < len(bSplit); idx++ {
+		aInt, aErr := strconv.ParseInt(aSplit[idx], 10, 64)
+		bInt, bErr := strconv.ParseInt(bSplit[idx], 10, 64)
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if aInt < bInt {
+				return -1
+			} else if aInt > bInt {
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aSplit[idx], bSplit[idx]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	if len(aSplit) < len(bSplit) {
+		return -1
+	} else if len(aSplit) > len(bSplit) {
+		return 1
+	}
+
+	return 0
+}
+
+var dottedConstraintPattern = regexp.MustCompile(`^(=|!=|>=|<=|>|<)?\s*(.+)$`)
+
+// dottedConstraint matches if any of its groups have all comparisons match.
+type dottedConstraint [][]dottedComparison
+
+type dottedComparison struct {
+	op       string
+	version  dottedVersion
+	wildcard int
+}
+
+func parseDottedConstraint(constraint string, parse func(string) (Version, error)) (VersionConstraint, error) {
+	var result dottedConstraint
+
+	for _, group := range strings.Split(constraint, "||") {
+		var comparisons []dottedComparison
+
+		for _, expression := range strings.Split(group, ",") {
+			match := dottedConstraintPattern.FindStringSubmatch(strings.TrimSpace(expression))
+			if match == nil {
+				return nil, fmt.Errorf("invalid constraint: %s", constraint)
+			}
+
+			comparison := dottedComparison{
+				op:       match[1],
+				wildcard: -1,
+			}
+
+			versionString := match[2]
+
+			// a wildcard matches any value of its component and those after it
+			split := strings.Split(versionString, ".")
+			for idx, segment := range split {
+				if segment == "x" || segment == "X" || segment == "*" {
+					comparison.wildcard = idx
+					versionString = strings.Join(split[:idx], ".")
+
+					break
+				}
+			}
+
+			if comparison.wildcard == 0 {
+				// matches everything
+				continue
+			} else if comparison.wildcard > 0 && comparison.op != "" && comparison.op != "=" {
+				return nil, fmt.Errorf("invalid constraint: %s: wildcards only support equality", constraint)
+			}
+
+			version, err := parse(versionString)
+			if err != nil {
+				// wildcard prefixes (e.g. a year without a month) may not be valid
+				// versions of the scheme on their own
+				version, err = parseAnyDottedVersion(versionString)
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid constraint: %s", constraint)
+				}
+			}
+
+			comparison.version = version.(dottedVersion)
+			comparisons = append(comparisons, comparison)
+		}
+
+		result = append(result, comparisons)
+	}
+
+	return result, nil
+}
+
+func (c dottedConstraint) Check(version Version) bool {
+	v, ok := version.(dottedVersion)
+	if !ok {
+		return false
+	}
+
+	for _, group := range c {
+		match := true
+
+		for _, comparison := range group {
+			match = match && comparison.check(v)
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c dottedComparison) check(v dottedVersion) bool {
+	if c.wildcard > 0 {
+		for idx := 0; idx < c.wildcard; idx++ {
+			if idx >= len(v.segments) || v.segments[idx] != c.version.segments[idx] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	compare := v.Compare(c.version)
+
+	switch c.op {
+	case "", "=":
+		return compare == 0
+	case "!=":
+		return compare != 0
+	case ">":
+		return compare > 0
+	case ">=":
+		return compare >= 0
+	case "<":
+		return compare < 0
+	case "<=":
+		return compare <= 0
+	}
+
+	return false
+}
@@ -0,0 +1,157 @@
+package boshrelease_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VersionScheme", func() {
+	sorted := func(scheme VersionScheme, versions ...string) []string {
+		var parsed []Version
+
+		for _, version := range versions {
+			v, err := scheme.Parse(version)
+			Expect(err).NotTo(HaveOccurred())
+
+			parsed = append(parsed, v)
+		}
+
+		sort.Slice(parsed, func(i, j int) bool {
+			return parsed[i].Compare(parsed[j]) < 0
+		})
+
+		var result []string
+
+		for _, v := range parsed {
+			result = append(result, v.Original())
+		}
+
+		return result
+	}
+
+	check := func(scheme VersionScheme, constraint string, versions ...string) []string {
+		parsed, err := scheme.ParseConstraint(constraint)
+		Expect(err).NotTo(HaveOccurred())
+
+		var result []string
+
+		for _, version := range versions {
+			v, err := scheme.Parse(version)
+			Expect(err).NotTo(HaveOccurred())
+
+			if parsed.Check(v) {
+				result = append(result, version)
+			}
+		}
+
+		return result
+	}
+
+	It("parses schemes by name", func() {
+		Expect(ParseVersionScheme("")).To(Equal(SemverScheme{}))
+		Expect(ParseVersionScheme("dotted")).To(Equal(DottedScheme{}))
+		Expect(ParseVersionScheme("calver")).To(Equal(CalVerScheme{}))
+
+		_, err := ParseVersionScheme("roman")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("SemverScheme", func() {
+		It("orders and constrains semver versions", func() {
+			Expect(sorted(SemverScheme{}, "2.0.0", "2.0.0-rc.1", "10.0.0", "1.2")).To(Equal([]string{"1.2", "2.0.0-rc.1", "2.0.0", "10.0.0"}))
+			Expect(check(SemverScheme{}, "~1.2", "1.2", "1.2.5", "1.3.0")).To(Equal([]string{"1.2", "1.2.5"}))
+		})
+	})
+
+	Describe("DottedScheme", func() {
+		It("orders any number of components numerically", func() {
+			Expect(sorted(DottedScheme{}, "264.7.1.2", "264.10", "v1", "264.7", "264.7.1", "264.7-rc.2", "264.7-rc.10")).To(Equal([]string{"v1", "264.7-rc.2", "264.7-rc.10", "264.7", "264.7.1", "264.7.1.2", "264.10"}))
+		})
+
+		It("rejects other versions", func() {
+			_, err := DottedScheme{}.Parse("one")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("supports comparisons, wildcards, and alternatives", func() {
+			versions := []string{"263.9", "264.0", "264.7.1.2", "265.1", "300"}
+
+			Expect(check(DottedScheme{}, "264.x", versions...)).To(Equal([]string{"264.0", "264.7.1.2"}))
+			Expect(check(DottedScheme{}, ">=264.1, <300", versions...)).To(Equal([]string{"264.7.1.2", "265.1"}))
+			Expect(check(DottedScheme{}, "263.* || 300", versions...)).To(Equal([]string{"263.9", "300"}))
+			Expect(check(DottedScheme{}, "!=264", versions...)).To(Equal([]string{"263.9", "264.7.1.2", "265.1", "300"}))
+		})
+
+		It("rejects invalid constraints", func() {
+			_, err := DottedScheme{}.ParseConstraint(">=264.x")
+			Expect(err).To(HaveOccurred())
+
+			_, err = DottedScheme{}.ParseConstraint("~> 1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CalVerScheme", func() {
+		It("orders calendar versions chronologically", func() {
+			Expect(sorted(CalVerScheme{}, "2024.10", "2024.06.13.2", "2023.12.1", "2024.06")).To(Equal([]string{"2023.12.1", "2024.06", "2024.06.13.2", "2024.10"}))
+		})
+
+		It("requires a year and month", func() {
+			_, err := CalVerScheme{}.Parse("1.2.3")
+			Expect(err).To(HaveOccurred())
+
+			_, err = CalVerScheme{}.Parse("2024.13")
+			Expect(err).To(HaveOccurred())
+
+			_, err = CalVerScheme{}.Parse("24.04.1")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("supports dotted constraints", func() {
+			Expect(check(CalVerScheme{}, ">=2024.06", "2024.01", "2024.06", "2025.01")).To(Equal([]string{"2024.06", "2025.01"}))
+			Expect(check(CalVerScheme{}, "2024.x", "2023.12", "2024.01", "2025.01")).To(Equal([]string{"2024.01"}))
+		})
+	})
+	Describe("Release versions", func() {
+		var releasedir string
+
+		BeforeEach(func() {
+			var err error
+
+			releasedir, err = ioutil.TempDir("", "bosh-release-resource-scheme")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(releasedir, "releases", "fake"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(releasedir, "releases", "fake", "index.yml"), []byte(`---
+builds:
+  a: {version: "264.7"}
+  b: {version: "nightly"}
+  c: {version: "264.10"}
+format-version: "2"
+`), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(releasedir)).To(Succeed())
+		})
+
+		It("returns the versions which the scheme cannot parse", func() {
+			subject := NewRelease(NewLocalRepository(releasedir), nil)
+			subject.SetVersionScheme(DottedScheme{})
+
+			versions, invalid, err := subject.Versions("fake", VersionFilter{}, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(HaveLen(2))
+			Expect(versions[0].Original()).To(Equal("264.7"))
+			Expect(versions[1].Original()).To(Equal("264.10"))
+			Expect(invalid).To(HaveLen(1))
+			Expect(invalid[0].Error()).To(ContainSubstring("parsing version nightly"))
+		})
+	})
+})
@@ -0,0 +1,181 @@
+package boshrelease
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// JobSpec is the spec file of a job.
+type JobSpec struct {
+	Name        string                     `yaml:"name" json:"name"`
+	Description string                     `yaml:"description,omitempty" json:"description,omitempty"`
+	Templates   map[string]string          `yaml:"templates" json:"templates"`
+	Packages    []string                   `yaml:"packages" json:"packages"`
+	Provides    []JobSpecLink              `yaml:"provides,omitempty" json:"provides,omitempty"`
+	Consumes    []JobSpecLink              `yaml:"consumes,omitempty" json:"consumes,omitempty"`
+	Properties  map[string]JobSpecProperty `yaml:"properties" json:"properties"`
+}
+
+type JobSpecLink struct {
+	Name       string   `yaml:"name" json:"name"`
+	Type       string   `yaml:"type" json:"type"`
+	Optional   bool     `yaml:"optional,omitempty" json:"optional,omitempty"`
+	Properties []string `yaml:"properties,omitempty" json:"properties,omitempty"`
+}
+
+type JobSpecProperty struct {
+	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
+	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+	Example     interface{} `yaml:"example,omitempty" json:"example,omitempty"`
+}
+
+// ParseJobSpec parses a job spec file.
+func ParseJobSpec(bytes []byte) (JobSpec, error) {
+	var spec JobSpec
+
+	err := yaml.Unmarshal(bytes, &spec)
+	if err != nil {
+		return spec, err
+	}
+
+	for name, property := range spec.Properties {
+		property.Default = normalizeYAML(property.Default)
+		property.Example = normalizeYAML(property.Example)
+		spec.Properties[name] = property
+	}
+
+	return spec, nil
+}
+
+// JobSpec loads the spec of a job at a commit, or nil if the job does not
+// exist at the commit. An error is returned if the commit is not in the
+// repository.
+func (r Release) JobSpec(commitish, name string) (*JobSpec, error) {
+	if !r.repository.HasCommit(commitish) {
+		return nil, fmt.Errorf("commit not found: %s", commitish)
+	}
+
+	specPath := path.Join("jobs", name, "spec")
+
+	if !r.repository.HasFile(commitish, specPath) {
+		return nil, nil
+	}
+
+	bytes, err := r.repository.Show(commitish, specPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", specPath)
+	}
+
+	spec, err := ParseJobSpec(bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", specPath)
+	}
+
+	return &spec, nil
+}
+
+// JobSpecs loads the specs of all jobs at a commit, ordered by name. An error
+// is returned if the commit is not in the repository.
+func (r Release) JobSpecs(commitish string) ([]JobSpec, error) {
+	if !r.repository.HasCommit(commitish) {
+		return nil, fmt.Errorf("commit not found: %s", commitish)
+	}
+
+	if !r.repository.HasFile(commitish, "jobs") {
+		return nil, nil
+	}
+
+	names, err := r.repository.ListDir(commitish, "jobs")
+	if err != nil {
+		return nil, errors.Wrap(err, "listing jobs")
+	}
+
+	sort.Strings(names)
+
+	var specs []JobSpec
+
+	for _, name := range names {
+		spec, err := r.JobSpec(commitish, name)
+		if err != nil {
+			return nil, err
+		} else if spec == nil {
+			// not a job directory
+			continue
+		}
+
+		specs = append(specs, *spec)
+	}
+
+	return specs, nil
+}
+
+// Blob is an entry of config/blobs.yml.
+type Blob struct {
+	Size     int64  `yaml:"size" json:"size"`
+	ObjectID string `yaml:"object_id,omitempty" json:"object_id,omitempty"`
+	SHA      string `yaml:"sha" json:"sha"`
+}
+
+// Blobs loads config/blobs.yml at a commit. No blobs are returned if the file
+// does not exist at the commit.
+func (r Release) Blobs(commitish string) (map[string]Blob, error) {
+	blobsPath := path.Join("config", "blobs.yml")
+
+	if !r.repository.HasFile(commitish, blobsPath) {
+		return nil, nil
+	}
+
+	bytes, err := r.repository.Show(commitish, blobsPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", blobsPath)
+	}
+
+	var blobs blobsConfig
+
+	err = yaml.Unmarshal(bytes, &blobs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", blobsPath)
+	}
+
+	return blobs, nil
+}
+
+// FinalBuild is an entry of a .final_builds index.
+type FinalBuild struct {
+	Version     string `yaml:"version" json:"version"`
+	SHA1        string `yaml:"sha1" json:"sha1"`
+	BlobstoreID string `yaml:"blobstore_id" json:"blobstore_id"`
+}
+
+// FinalBuild loads the final build of a job or package (kind is `jobs` or
+// `packages`) by fingerprint, or nil if it is not in the index.
+func (r Release) FinalBuild(kind, name, fingerprint string) (*FinalBuild, error) {
+	indexPath := path.Join(r.repository.Path(), ".final_builds", kind, name, "index.yml")
+
+	bytes, err := ioutil.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", indexPath)
+	}
+
+	var index finalBuildsIndex
+
+	err = yaml.Unmarshal(bytes, &index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", indexPath)
+	}
+
+	build, found := index.Builds[fingerprint]
+	if !found {
+		return nil, nil
+	}
+
+	return &build, nil
+}
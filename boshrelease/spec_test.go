@@ -0,0 +1,106 @@
+package boshrelease_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Release", func() {
+	Describe("JobSpecs", func() {
+		var releasedir string
+		var subject *Release
+
+		BeforeEach(func() {
+			var err error
+
+			releasedir, err = ioutil.TempDir("", "bosh-release-resource-spec")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(releasedir, "jobs", "web"), 0755)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(releasedir, "jobs", "db"), 0755)).To(Succeed())
+
+			Expect(ioutil.WriteFile(filepath.Join(releasedir, "jobs", "web", "spec"), []byte(`---
+name: web
+description: serves requests
+templates:
+  ctl.erb: bin/ctl
+packages:
+- golang
+consumes:
+- name: database
+  type: db
+  optional: true
+provides:
+- name: web
+  type: http
+  properties:
+  - port
+properties:
+  port:
+    description: listening port
+    default: 8080
+  tls:
+    description: tls settings
+    example: {cert: "...", key: "..."}
+`), 0644)).To(Succeed())
+
+			Expect(ioutil.WriteFile(filepath.Join(releasedir, "jobs", "db", "spec"), []byte("name: db\n"), 0644)).To(Succeed())
+
+			Expect(testing.RunCommands(releasedir, []string{
+				"git init .",
+				"git add . && git -c user.name=test -c user.email=test@localhost commit -m init",
+				"echo 'name: removed' > jobs/db/spec && git add . && git -c user.name=test -c user.email=test@localhost commit -m later",
+			})).To(Succeed())
+
+			subject = NewRelease(NewLocalRepository(releasedir), nil)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(releasedir)).To(Succeed())
+		})
+
+		It("parses every job spec at the commit", func() {
+			specs, err := subject.JobSpecs("HEAD^")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(specs).To(HaveLen(2))
+
+			Expect(specs[0].Name).To(Equal("db"))
+			Expect(specs[1].Name).To(Equal("web"))
+			Expect(specs[1].Description).To(Equal("serves requests"))
+			Expect(specs[1].Templates).To(Equal(map[string]string{"ctl.erb": "bin/ctl"}))
+			Expect(specs[1].Consumes).To(Equal([]JobSpecLink{{Name: "database", Type: "db", Optional: true}}))
+			Expect(specs[1].Provides).To(Equal([]JobSpecLink{{Name: "web", Type: "http", Properties: []string{"port"}}}))
+			Expect(specs[1].Properties["port"]).To(Equal(JobSpecProperty{Description: "listening port", Default: 8080}))
+
+			jsonBytes, err := json.Marshal(specs[1].Properties["tls"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(jsonBytes)).To(Equal(`{"description":"tls settings","example":{"cert":"...","key":"..."}}`))
+		})
+
+		It("returns no specs for a commit without jobs", func() {
+			Expect(testing.RunCommands(releasedir, []string{
+				"git rm -rq jobs && git -c user.name=test -c user.email=test@localhost commit -m removed",
+			})).To(Succeed())
+
+			specs, err := subject.JobSpecs("HEAD")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(specs).To(BeEmpty())
+		})
+
+		It("fails for an unknown commit", func() {
+			_, err := subject.JobSpecs("0123456789abcdef0123456789abcdef01234567")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("commit not found"))
+
+			_, err = subject.JobSpec("0123456789abcdef0123456789abcdef01234567", "web")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
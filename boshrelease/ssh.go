@@ -0,0 +1,230 @@
+package boshrelease
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// askpass answers passphrase prompts of ssh-add from the environment so the
+// passphrase is never written to disk or passed as an argument. It only
+// answers once since ssh-add otherwise keeps retrying a bad passphrase.
+const askpass = `#!/bin/sh
+[ -e "$0.used" ] && exit 1
+touch "$0.used"
+echo "$BOSH_RELEASE_SSH_PASSPHRASE"
+`
+
+var sshAgentPID = regexp.MustCompile(`SSH_AGENT_PID=(\d+)`)
+
+type SSHKey struct {
+	// URI is the remote the key is used for; it is ignored for the main key.
+	URI        string
+	PrivateKey string
+	Passphrase string
+}
+
+// sshCommand loads the configured keys into dedicated ssh-agents and returns a
+// GIT_SSH_COMMAND which selects the agent based on the remote being accessed.
+// The caller is responsible for calling the cleanup function once git has
+// finished.
+func (r Repository) sshCommand() (string, func(), error) {
+	dir, err := ioutil.TempDir("", "git-ssh")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating tempdir")
+	}
+
+	var agents []int
+
+	cleanup := func() {
+		for _, pid := range agents {
+			syscall.Kill(pid, syscall.SIGTERM)
+		}
+
+		os.RemoveAll(dir)
+	}
+
+	fail := func(err error, message string) (string, func(), error) {
+		cleanup()
+
+		return "", nil, errors.Wrap(err, message)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "askpass"), []byte(askpass), 0700)
+	if err != nil {
+		return fail(err, "writing askpass")
+	}
+
+	addKey := func(key SSHKey) (string, error) {
+		socket := filepath.Join(dir, fmt.Sprintf("agent-%d.sock", len(agents)))
+
+		pid, err := startSSHAgent(socket)
+		if err != nil {
+			return "", errors.Wrap(err, "starting ssh-agent")
+		}
+
+		agents = append(agents, pid)
+
+		keyPath := filepath.Join(dir, fmt.Sprintf("key-%d", len(agents)))
+
+		err = ioutil.WriteFile(keyPath, []byte(key.PrivateKey), 0600)
+		if err != nil {
+			return "", errors.Wrap(err, "writing private key")
+		}
+
+		err = os.RemoveAll(filepath.Join(dir, "askpass.used"))
+		if err != nil {
+			return "", errors.Wrap(err, "resetting askpass")
+		}
+
+		stderr := &bytes.Buffer{}
+
+		cmd := exec.Command("ssh-add", keyPath)
+		cmd.Env = append(
+			os.Environ(),
+			fmt.Sprintf("SSH_AUTH_SOCK=%s", socket),
+			fmt.Sprintf("SSH_ASKPASS=%s", filepath.Join(dir, "askpass")),
+			"SSH_ASKPASS_REQUIRE=force",
+			"DISPLAY=bosh-release-resource:0",
+			fmt.Sprintf("BOSH_RELEASE_SSH_PASSPHRASE=%s", key.Passphrase),
+		)
+		cmd.Stdout = stderr
+		cmd.Stderr = stderr
+
+		err = cmd.Run()
+		if err != nil {
+			message := strings.TrimSpace(stderr.String())
+			if message == "" {
+				message = "incorrect or missing passphrase"
+			}
+
+			return "", errors.Wrapf(err, "adding private key: %s", message)
+		}
+
+		return socket, nil
+	}
+
+	sshArgs := []string{"-o", "BatchMode=yes", "-o", "LogLevel=ERROR"}
+
+	if r.config.KnownHosts != "" {
+		knownHosts := filepath.Join(dir, "known_hosts")
+
+		err = ioutil.WriteFile(knownHosts, []byte(r.config.KnownHosts), 0600)
+		if err != nil {
+			return fail(err, "writing known_hosts")
+		}
+
+		sshArgs = append(sshArgs, "-o", "StrictHostKeyChecking=yes", "-o", fmt.Sprintf("UserKnownHostsFile=%s", knownHosts))
+	} else {
+		sshArgs = append(sshArgs, "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null")
+	}
+
+	wrapper := &bytes.Buffer{}
+
+	fmt.Fprintf(wrapper, `#!/bin/bash
+
+set -eu
+
+# git invokes ssh with [options...] [user@]host command; the command includes
+# the quoted repository path (e.g. git-upload-pack 'owner/repo.git')
+host="${@: -2:1}"
+host="${host#*@}"
+path="${@: -1}"
+path="${path#* }"
+path="${path//\'/}"
+path="${path#/}"
+path="${path%%.git}"
+
+`)
+
+	if r.config.PrivateKey != "" {
+		socket, err := addKey(SSHKey{PrivateKey: r.config.PrivateKey, Passphrase: r.config.PrivateKeyPassphrase})
+		if err != nil {
+			return fail(err, "private_key")
+		}
+
+		fmt.Fprintf(wrapper, "export SSH_AUTH_SOCK=%s\n", shellQuote(socket))
+	}
+
+	if len(r.config.SubmoduleKeys) > 0 {
+		fmt.Fprintf(wrapper, "\ncase \"$host/$path\" in\n")
+
+		for idx, key := range r.config.SubmoduleKeys {
+			host, path, ok := ParseRemote(key.URI)
+			if !ok {
+				return fail(fmt.Errorf("unsupported uri: %s", key.URI), fmt.Sprintf("submodule key %d", idx))
+			}
+
+			socket, err := addKey(key)
+			if err != nil {
+				return fail(err, fmt.Sprintf("submodule key %s", key.URI))
+			}
+
+			fmt.Fprintf(wrapper, "  %s) export SSH_AUTH_SOCK=%s ;;\n", shellQuote(fmt.Sprintf("%s/%s", host, path)), shellQuote(socket))
+		}
+
+		fmt.Fprintf(wrapper, "esac\n")
+	}
+
+	for idx, arg := range sshArgs {
+		sshArgs[idx] = shellQuote(arg)
+	}
+
+	fmt.Fprintf(wrapper, "\nexec ssh %s \"$@\"\n", strings.Join(sshArgs, " "))
+
+	wrapperPath := filepath.Join(dir, "ssh")
+
+	err = ioutil.WriteFile(wrapperPath, wrapper.Bytes(), 0700)
+	if err != nil {
+		return fail(err, "writing ssh wrapper")
+	}
+
+	return shellQuote(wrapperPath), cleanup, nil
+}
+
+func startSSHAgent(socket string) (int, error) {
+	stdout := &bytes.Buffer{}
+
+	cmd := exec.Command("ssh-agent", "-s", "-a", socket)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+
+	for scanner.Scan() {
+		if match := sshAgentPID.FindStringSubmatch(scanner.Text()); match != nil {
+			return strconv.Atoi(match[1])
+		}
+	}
+
+	return 0, errors.New("parsing ssh-agent output")
+}
+
+func wrapSSHError(err error, stderr string) error {
+	if strings.Contains(stderr, "REMOTE HOST IDENTIFICATION HAS CHANGED") {
+		return errors.Wrap(err, "ssh host key mismatch: the remote host key does not match known_hosts")
+	} else if strings.Contains(stderr, "Host key verification failed") {
+		return errors.Wrap(err, "ssh host key verification failed: the remote host key is not in known_hosts")
+	}
+
+	return err
+}
+
+func shellQuote(arg string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(arg, "'", `'\''`, -1))
+}
@@ -0,0 +1,271 @@
+package boshrelease
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ReleaseTarball reads the metadata of a release tarball without the bosh CLI.
+type ReleaseTarball struct {
+	path     string
+	manifest ReleaseManifest
+}
+
+// OpenReleaseTarball parses the release.MF of a release tarball.
+func OpenReleaseTarball(path string) (*ReleaseTarball, error) {
+	t := &ReleaseTarball{
+		path: path,
+	}
+
+	var found bool
+
+	err := t.walk(func(name string, reader io.Reader) error {
+		if name != "release.MF" {
+			return nil
+		}
+
+		bytes, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return errors.Wrap(err, "reading release.MF")
+		}
+
+		err = yaml.Unmarshal(bytes, &t.manifest)
+		if err != nil {
+			return errors.Wrap(err, "parsing release.MF")
+		}
+
+		found = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, errors.New("release.MF not found")
+	}
+
+	return t, nil
+}
+
+func (t ReleaseTarball) Path() string {
+	return t.path
+}
+
+func (t ReleaseTarball) Manifest() ReleaseManifest {
+	return t.manifest
+}
+
+func (t ReleaseTarball) Jobs() []ReleaseManifestArtifact {
+	return t.manifest.Jobs
+}
+
+// Packages returns the source packages, or the compiled packages of a compiled
+// release.
+func (t ReleaseTarball) Packages() []ReleaseManifestArtifact {
+	if len(t.manifest.CompiledPackages) > 0 {
+		return t.manifest.CompiledPackages
+	}
+
+	return t.manifest.Packages
+}
+
+// Verify recomputes the digests of every job, package, and license of the
+// tarball and returns an error describing any which are missing or do not
+// match release.MF.
+func (t ReleaseTarball) Verify() error {
+	expected := map[string]string{}
+	labels := map[string]string{}
+
+	for _, job := range t.manifest.Jobs {
+		expected[fmt.Sprintf("jobs/%s.tgz", job.Name)] = job.SHA1
+		labels[fmt.Sprintf("jobs/%s.tgz", job.Name)] = fmt.Sprintf("job %s", job.Name)
+	}
+
+	for _, pkg := range t.manifest.Packages {
+		expected[fmt.Sprintf("packages/%s.tgz", pkg.Name)] = pkg.SHA1
+		labels[fmt.Sprintf("packages/%s.tgz", pkg.Name)] = fmt.Sprintf("package %s", pkg.Name)
+	}
+
+	for _, pkg := range t.manifest.CompiledPackages {
+		expected[fmt.Sprintf("compiled_packages/%s.tgz", pkg.Name)] = pkg.SHA1
+		labels[fmt.Sprintf("compiled_packages/%s.tgz", pkg.Name)] = fmt.Sprintf("compiled package %s", pkg.Name)
+	}
+
+	if t.manifest.License != nil {
+		expected["license.tgz"] = t.manifest.License.SHA1
+		labels["license.tgz"] = "license"
+	}
+
+	var problems []string
+
+	err := t.walk(func(name string, reader io.Reader) error {
+		digest, known := expected[name]
+		if !known {
+			return nil
+		}
+
+		delete(expected, name)
+
+		problem, err := verifyDigest(digest, reader)
+		if err != nil {
+			return errors.Wrapf(err, "digesting %s", name)
+		} else if problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", labels[name], problem))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name := range expected {
+		problems = append(problems, fmt.Sprintf("%s: missing from tarball", labels[name]))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("verifying tarball: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// VerifyRelease confirms the tarball is of the release name and version and,
+// when commit is not empty, was created from the commit (either of which may
+// be abbreviated). When allowDev is set, a dev version is accepted instead of
+// version since it is replaced when the tarball is finalized. Its digests are
+// then verified.
+func (t ReleaseTarball) VerifyRelease(name, version, commit string, allowDev bool) error {
+	if t.manifest.Name != name {
+		return fmt.Errorf("expected release %s but found %s", name, t.manifest.Name)
+	} else if t.manifest.Version != version && !(allowDev && isDevVersion(t.manifest.Version)) {
+		return fmt.Errorf("expected version %s but found %s", version, t.manifest.Version)
+	} else if commit != "" && !sameCommit(t.manifest.CommitHash, commit) {
+		return fmt.Errorf("expected commit %s but found %s", commit, t.manifest.CommitHash)
+	}
+
+	return t.Verify()
+}
+
+// isDevVersion matches the versions of releases which are not final (e.g.
+// 1.2.3+dev.4).
+func isDevVersion(version string) bool {
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Prerelease() != "" || parsed.Metadata() != ""
+}
+
+// sameCommit compares commits which may be abbreviated to different lengths.
+func sameCommit(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// walk calls fn with the normalized path and contents of every file in the
+// tarball.
+func (t ReleaseTarball) walk(fn func(name string, reader io.Reader) error) error {
+	fh, err := os.Open(t.path)
+	if err != nil {
+		return errors.Wrap(err, "opening tarball")
+	}
+
+	defer fh.Close()
+
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		return errors.Wrap(err, "decompressing tarball")
+	}
+
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "reading tarball")
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		err = fn(strings.TrimPrefix(path.Clean(header.Name), "/"), tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// verifyDigest checks the contents against a digest of release.MF, which is a
+// sha1 or a semicolon-separated list of `algorithm:hex` digests (e.g.
+// `sha256:...`). A description of the mismatch is returned, if any.
+func verifyDigest(digest string, reader io.Reader) (string, error) {
+	hashes := map[string]hash.Hash{}
+	var writers []io.Writer
+
+	for _, expected := range strings.Split(digest, ";") {
+		algorithm := "sha1"
+
+		if split := strings.SplitN(expected, ":", 2); len(split) == 2 {
+			algorithm = split[0]
+		}
+
+		if _, found := hashes[algorithm]; found {
+			continue
+		}
+
+		switch algorithm {
+		case "sha1":
+			hashes[algorithm] = sha1.New()
+		case "sha256":
+			hashes[algorithm] = sha256.New()
+		default:
+			return "", fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+		}
+
+		writers = append(writers, hashes[algorithm])
+	}
+
+	_, err := io.Copy(io.MultiWriter(writers...), reader)
+	if err != nil {
+		return "", err
+	}
+
+	for _, expected := range strings.Split(digest, ";") {
+		algorithm, value := "sha1", expected
+
+		if split := strings.SplitN(expected, ":", 2); len(split) == 2 {
+			algorithm, value = split[0], split[1]
+		}
+
+		actual := hex.EncodeToString(hashes[algorithm].Sum(nil))
+		if actual != value {
+			return fmt.Sprintf("expected %s %s but found %s", algorithm, value, actual), nil
+		}
+	}
+
+	return "", nil
+}
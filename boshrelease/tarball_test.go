@@ -0,0 +1,185 @@
+package boshrelease_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/dpb587/bosh-release-resource/boshrelease"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReleaseTarball", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "bosh-release-resource-tarball")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	writeTarball := func(files map[string]string) string {
+		tarballPath := filepath.Join(tmpdir, "release.tgz")
+
+		fh, err := os.Create(tarballPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		defer fh.Close()
+
+		gz := gzip.NewWriter(fh)
+		tw := tar.NewWriter(gz)
+
+		for name, contents := range files {
+			Expect(tw.WriteHeader(&tar.Header{
+				Name:     name,
+				Mode:     0644,
+				Size:     int64(len(contents)),
+				Typeflag: tar.TypeReg,
+			})).To(Succeed())
+
+			_, err = tw.Write([]byte(contents))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+
+		return tarballPath
+	}
+
+	releaseMF := func(jobDigest, packageDigest string) string {
+		return fmt.Sprintf(`name: fake
+version: 1.2.3+dev.4
+commit_hash: abcdef0
+uncommitted_changes: true
+jobs:
+- name: fake-job
+  version: job-fingerprint
+  fingerprint: job-fingerprint
+  sha1: %s
+packages:
+- name: fake-package
+  version: package-fingerprint
+  fingerprint: package-fingerprint
+  sha1: %s
+  dependencies: []
+`, jobDigest, packageDigest)
+	}
+
+	It("parses release.MF", func() {
+		subject, err := OpenReleaseTarball(writeTarball(map[string]string{
+			"./release.MF": releaseMF("unused", "unused"),
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(subject.Manifest().Name).To(Equal("fake"))
+		Expect(subject.Manifest().Version).To(Equal("1.2.3+dev.4"))
+		Expect(subject.Manifest().CommitHash).To(Equal("abcdef0"))
+		Expect(subject.Manifest().UncommittedChanges).To(BeTrue())
+		Expect(subject.Jobs()).To(Equal([]ReleaseManifestArtifact{
+			{Name: "fake-job", Version: "job-fingerprint", Fingerprint: "job-fingerprint", SHA1: "unused"},
+		}))
+		Expect(subject.Packages()).To(HaveLen(1))
+		Expect(subject.Packages()[0].Fingerprint).To(Equal("package-fingerprint"))
+	})
+
+	It("requires release.MF", func() {
+		_, err := OpenReleaseTarball(writeTarball(map[string]string{
+			"./jobs/fake-job.tgz": "job",
+		}))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("release.MF not found"))
+	})
+
+	It("verifies sha1 and sha256 digests", func() {
+		subject, err := OpenReleaseTarball(writeTarball(map[string]string{
+			"./release.MF":                releaseMF(fmt.Sprintf("%x", sha1.Sum([]byte("job"))), fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("package")))),
+			"./jobs/fake-job.tgz":         "job",
+			"./packages/fake-package.tgz": "package",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Verify()).To(Succeed())
+	})
+
+	It("describes mismatched and missing artifacts", func() {
+		subject, err := OpenReleaseTarball(writeTarball(map[string]string{
+			"./release.MF":        releaseMF(fmt.Sprintf("%x", sha1.Sum([]byte("job"))), fmt.Sprintf("%x", sha1.Sum([]byte("package")))),
+			"./jobs/fake-job.tgz": "corrupt",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = subject.Verify()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("job fake-job: expected sha1 %x but found %x", sha1.Sum([]byte("job")), sha1.Sum([]byte("corrupt")))))
+		Expect(err.Error()).To(ContainSubstring("package fake-package: missing from tarball"))
+	})
+	Describe("VerifyRelease", func() {
+		var subject *ReleaseTarball
+
+		BeforeEach(func() {
+			var err error
+
+			subject, err = OpenReleaseTarball(writeTarball(map[string]string{
+				"./release.MF":                releaseMF(fmt.Sprintf("%x", sha1.Sum([]byte("job"))), fmt.Sprintf("%x", sha1.Sum([]byte("package")))),
+				"./jobs/fake-job.tgz":         "job",
+				"./packages/fake-package.tgz": "package",
+			}))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("accepts the expected release with abbreviated commits", func() {
+			Expect(subject.VerifyRelease("fake", "1.2.3+dev.4", "abcdef0123456789", false)).To(Succeed())
+			Expect(subject.VerifyRelease("fake", "1.2.3+dev.4", "abcd", false)).To(Succeed())
+			Expect(subject.VerifyRelease("fake", "1.2.3+dev.4", "", false)).To(Succeed())
+		})
+
+		It("rejects a different name", func() {
+			Expect(subject.VerifyRelease("other", "1.2.3+dev.4", "", false)).To(MatchError("expected release other but found fake"))
+		})
+
+		It("rejects a different version", func() {
+			Expect(subject.VerifyRelease("fake", "1.2.3", "", false)).To(MatchError("expected version 1.2.3 but found 1.2.3+dev.4"))
+		})
+
+		It("accepts a dev version when allowed", func() {
+			Expect(subject.VerifyRelease("fake", "1.2.3", "", true)).To(Succeed())
+		})
+
+		It("rejects a different final version even when dev versions are allowed", func() {
+			final, err := OpenReleaseTarball(writeTarball(map[string]string{
+				"./release.MF": "name: fake\nversion: 1.2.2\njobs: []\npackages: []\n",
+			}))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(final.VerifyRelease("fake", "1.2.3", "", true)).To(MatchError("expected version 1.2.3 but found 1.2.2"))
+		})
+
+		It("rejects a different commit", func() {
+			Expect(subject.VerifyRelease("fake", "1.2.3+dev.4", "1234567", false)).To(MatchError("expected commit 1234567 but found abcdef0"))
+		})
+
+		It("rejects corrupt artifacts", func() {
+			corrupt, err := OpenReleaseTarball(writeTarball(map[string]string{
+				"./release.MF":                releaseMF(fmt.Sprintf("%x", sha1.Sum([]byte("job"))), fmt.Sprintf("%x", sha1.Sum([]byte("package")))),
+				"./jobs/fake-job.tgz":         "job",
+				"./packages/fake-package.tgz": "corrupt",
+			}))
+			Expect(err).NotTo(HaveOccurred())
+
+			err = corrupt.VerifyRelease("fake", "1.2.3+dev.4", "abcdef0", false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("package fake-package: expected sha1"))
+		})
+	})
+})
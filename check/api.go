@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/dpb587/bosh-release-resource/api"
+	"github.com/dpb587/bosh-release-resource/boshrelease"
 )
 
 type Request struct {
@@ -10,3 +11,7 @@ type Request struct {
 }
 
 type Response []api.Version
+
+type GarbageResponse struct {
+	Removed []boshrelease.CacheEntry `json:"removed"`
+}
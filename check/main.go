@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/Masterminds/semver"
 	"github.com/dpb587/bosh-release-resource/api"
@@ -20,14 +19,40 @@ func main() {
 		api.Fatal(errors.Wrap(err, "bad stdin: parse error"))
 	}
 
-	repository := boshrelease.NewRepository(request.Source.URI, request.Source.Branch, request.Source.PrivateKey)
+	if request.Source.DevReleases {
+		// dev versions are every commit, so final version filters do not apply
+		unsupported := []struct {
+			name string
+			set  bool
+		}{
+			{"version_any", len(request.Source.VersionAny) > 0},
+			{"skip_versions", len(request.Source.SkipVersions) > 0},
+			{"pre_releases", request.Source.PreReleases != ""},
+			{"latest_per", request.Source.LatestPer != ""},
+		}
+
+		for _, option := range unsupported {
+			if option.set {
+				api.Fatal(fmt.Errorf("bad source: %s is not supported with dev_releases", option.name))
+			}
+		}
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "--gc" {
+		collectGarbage(request)
+
+		return
+	}
+
+	repository := request.Source.Repository()
+	repository.SkipSubmodules()
 
 	err = repository.Pull()
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad repository: pulling"))
 	}
 
-	release := boshrelease.NewRelease(repository, request.Source.PrivateConfig)
+	release := request.Source.Release(repository)
 
 	releaseName := request.Source.Name
 
@@ -38,7 +63,7 @@ func main() {
 		}
 	}
 
-	var versionsRaw []*semver.Version
+	var versions []string
 
 	if request.Source.DevReleases {
 		var sinceCommit string
@@ -49,57 +74,100 @@ func main() {
 				api.Fatal(errors.Wrap(err, "bad version: parsing"))
 			}
 
-			prereleaseSplit := strings.Split(version.Prerelease(), ".")
-			if len(prereleaseSplit) > 2 && prereleaseSplit[2] == "commit" {
-				sinceCommit = prereleaseSplit[3]
+			if commit, err := boshrelease.DevVersionCommit(version); err == nil {
+				sinceCommit = commit
 			}
 		}
 
-		versionsRaw, err = release.DevVersions(releaseName, sinceCommit)
+		devVersions, invalidVersions, err := release.DevVersions(releaseName, sinceCommit)
 		if err != nil {
 			api.Fatal(errors.Wrap(err, "bad release: versions"))
 		}
-	} else {
-		var constraints []*semver.Constraints
 
-		if request.Source.VersionConstraints != nil {
-			constraints = append(constraints, request.Source.VersionConstraints)
+		for _, err := range invalidVersions {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s\n", err)
 		}
 
+		for _, version := range devVersions {
+			versions = append(versions, version.Original())
+		}
+	} else {
+		filter := request.Source.VersionFilter
+
 		var sinceVersion string
 
-		if request.Version != nil {
+		// every line is emitted each time so newer versions of older lines are
+		// still discovered
+		if request.Version != nil && request.Source.LatestPerDepth == 0 {
 			sinceVersion = request.Version.Version
 
-			constraint, err := semver.NewConstraint(fmt.Sprintf(">%s", sinceVersion))
+			filter.After, err = request.Source.Scheme.Parse(sinceVersion)
 			if err != nil {
 				api.Fatal(errors.Wrap(err, "bad version: version"))
 			}
-
-			constraints = append(constraints, constraint)
 		}
 
-		versionsRaw, err = release.Versions(releaseName, constraints, sinceVersion)
+		finalVersions, invalidVersions, err := release.Versions(releaseName, filter, sinceVersion)
 		if err != nil {
 			api.Fatal(errors.Wrap(err, "bad release: versions"))
 		}
+
+		for _, err := range invalidVersions {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s\n", err)
+		}
+
+		if request.Source.LatestPerDepth > 0 {
+			finalVersions = boshrelease.LatestPerLine(finalVersions, request.Source.LatestPerDepth)
+		}
+
+		for _, version := range finalVersions {
+			versions = append(versions, version.Original())
+		}
 	}
 
 	response := Response{}
 
-	for _, version := range versionsRaw {
+	for _, version := range versions {
 		response = append(response, api.Version{
-			Version: version.Original(),
+			Version: version,
 		})
 	}
 
-	if l := len(response); l > 0 && request.Version == nil {
+	if l := len(response); l > 0 && request.Version == nil && request.Source.LatestPerDepth == 0 {
 		// if no prior version, only enumerate the most recent
 		response = response[l-1:]
 	}
 
+	err = repository.Close()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: closing"))
+	}
+
+	if !request.Source.CachePolicy.IsZero() {
+		_, err = boshrelease.CollectGarbage(request.Source.CacheDir, request.Source.CachePolicy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: collecting cache garbage: %s\n", err)
+		}
+	}
+
 	err = json.NewEncoder(os.Stdout).Encode(response)
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad stdout: json"))
 	}
 }
+
+// collectGarbage is a maintenance mode which removes abandoned worktrees and
+// shared clones exceeding the cache policy of the source.
+func collectGarbage(request Request) {
+	removed, err := boshrelease.CollectGarbage(request.Source.CacheDir, request.Source.CachePolicy)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad cache: collecting garbage"))
+	}
+
+	err = json.NewEncoder(os.Stdout).Encode(GarbageResponse{
+		Removed: removed,
+	})
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad stdout: json"))
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -278,4 +280,54 @@ var _ = Describe("Main", func() {
 			})
 		})
 	})
+
+	Context("--gc", func() {
+		var cachedir string
+
+		BeforeEach(func() {
+			var err error
+
+			cachedir, err = ioutil.TempDir("", "bosh-release-resource-cache")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cachedir)).To(Succeed())
+		})
+
+		It("removes stale clones without checking", func() {
+			stale := filepath.Join(cachedir, "bosh-release-0123456789abcdef0123456789abcdef01234567")
+			Expect(os.MkdirAll(stale, 0700)).To(Succeed())
+
+			past := time.Now().Add(-48 * time.Hour)
+			Expect(os.Chtimes(stale, past, past)).To(Succeed())
+
+			command := exec.Command(cli, "--gc")
+			command.Stdin = bytes.NewBufferString(fmt.Sprintf(`{
+		"source": {
+			"uri": "https://git.example.invalid/release.git",
+			"cache_dir": "%s",
+			"cache_max_age": "24h"
+		}
+	}`, cachedir))
+
+			stdout := &bytes.Buffer{}
+
+			session, err := gexec.Start(command, stdout, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			session.Wait(time.Minute)
+			Expect(session.ExitCode()).To(Equal(0))
+
+			var response map[string][]map[string]interface{}
+
+			err = json.Unmarshal(stdout.Bytes(), &response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response["removed"]).To(HaveLen(1))
+			Expect(response["removed"][0]).To(HaveKeyWithValue("path", stale))
+
+			_, err = os.Stat(stale)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/Masterminds/semver"
+	"github.com/dpb587/bosh-release-resource/api"
+	"github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/pkg/errors"
+)
+
+const defaultTarballName = "{{.Name}}-{{.Version}}.tgz"
+
+type Summary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Dirty   bool   `json:"dirty"`
+	Tarball string `json:"tarball"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		api.Fatal(errors.Wrap(fmt.Errorf("%s DESTINATION-DIR", os.Args[0]), "create-dev-release: bad invocation"))
+	}
+
+	destination, err := filepath.Abs(os.Args[1])
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad args: destination dir"))
+	}
+
+	tarballName := os.Getenv("tarball_name")
+	if tarballName == "" {
+		tarballName = defaultTarballName
+	}
+
+	tarballNameTmpl, err := template.New("tarball_name").Parse(tarballName)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad config: tarball_name"))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: working directory"))
+	}
+
+	repository := boshrelease.NewLocalRepository(cwd)
+	release := boshrelease.NewRelease(repository, nil)
+
+	releaseName := os.Getenv("name")
+
+	if releaseName == "" {
+		releaseName, err = release.Name()
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad release: discovering name"))
+		}
+	}
+
+	dirty, err := repository.IsDirty()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: checking status"))
+	} else if dirty && os.Getenv("dirty") != "true" {
+		api.Fatal(errors.New("bad repository: working tree has uncommitted changes (set dirty=true to include them)"))
+	}
+
+	devVersion, err := release.WorkingTreeVersion(releaseName)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release: version"))
+	}
+
+	commit, err := boshrelease.DevVersionCommit(devVersion)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release: version"))
+	}
+
+	version := os.Getenv("version")
+
+	if version == "" {
+		version = devVersion.Original()
+	} else if _, err := semver.NewVersion(version); err != nil {
+		api.Fatal(errors.Wrap(err, "bad config: version"))
+	}
+
+	tarballNameBuffer := &bytes.Buffer{}
+	err = tarballNameTmpl.Execute(tarballNameBuffer, struct {
+		Name    string
+		Version string
+	}{
+		Name:    releaseName,
+		Version: version,
+	})
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad config: generating tarball name"))
+	}
+
+	tarballPath := filepath.Join(destination, tarballNameBuffer.String())
+
+	if dirty {
+		err = release.CreateWorkingTreeTarball(releaseName, version, tarballPath)
+	} else {
+		err = createCommitTarball(cwd, releaseName, version, tarballPath)
+	}
+
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release"))
+	}
+
+	summary := Summary{
+		Name:    releaseName,
+		Version: version,
+		Commit:  commit,
+		Dirty:   dirty,
+		Tarball: tarballNameBuffer.String(),
+	}
+
+	summaryBytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad summary: json"))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(destination, "summary.json"), summaryBytes, 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "fs metadata: summary.json"))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(destination, "name"), []byte(releaseName), 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "fs metadata: name"))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(destination, "version"), []byte(version), 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "fs metadata: version"))
+	}
+}
+
+// createCommitTarball creates the release from a separate worktree of HEAD so
+// the checkout is not modified by bosh (e.g. dev_releases).
+func createCommitTarball(dir, name, version, tarball string) error {
+	repository := boshrelease.NewReadOnlyRepository(dir, "", boshrelease.RepositoryConfig{})
+
+	err := repository.Pull()
+	if err != nil {
+		return errors.Wrap(err, "checking out")
+	}
+
+	defer repository.Close()
+
+	// blobstore credentials are not committed
+	privateConfigBytes, err := ioutil.ReadFile(filepath.Join(dir, "config", "private.yml"))
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(repository.Path(), "config", "private.yml"), privateConfigBytes, 0600)
+		if err != nil {
+			return errors.Wrap(err, "copying private.yml")
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrap(err, "reading private.yml")
+	}
+
+	return boshrelease.NewRelease(repository, nil).CreateWorkingTreeTarball(name, version, tarball)
+}
@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Main", func() {
+	var releasedir, tmpdir string
+
+	BeforeEach(func() {
+		var err error
+
+		releasedir, err = testing.GenerateRelease()
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpdir, err = ioutil.TempDir("", "bosh-release-create-dev-release")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(releasedir)).To(Succeed())
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	runCLI := func(env ...string) *gexec.Session {
+		command := exec.Command(cli, tmpdir)
+		command.Dir = releasedir
+		command.Env = append(os.Environ(), env...)
+
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		session.Wait(time.Minute)
+
+		return session
+	}
+
+	loadSummary := func() map[string]interface{} {
+		summaryBytes, err := ioutil.ReadFile(filepath.Join(tmpdir, "summary.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var summary map[string]interface{}
+
+		Expect(json.Unmarshal(summaryBytes, &summary)).To(Succeed())
+
+		return summary
+	}
+
+	It("creates a dev release of the current commit", func() {
+		Expect(runCLI().ExitCode()).To(Equal(0))
+
+		lastCommit, err := testing.RunCommandStdout(releasedir, "git", "rev-parse", "--short", "HEAD")
+		Expect(err).NotTo(HaveOccurred())
+		lastCommit = strings.TrimSpace(lastCommit)
+
+		summary := loadSummary()
+		Expect(summary).To(HaveKeyWithValue("name", "fake"))
+		Expect(summary).To(HaveKeyWithValue("commit", lastCommit))
+		Expect(summary).To(HaveKeyWithValue("dirty", false))
+		Expect(summary["version"]).To(HaveSuffix(".commit." + lastCommit))
+
+		_, err = os.Stat(filepath.Join(tmpdir, summary["tarball"].(string)))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("supports custom versions and tarball names", func() {
+		Expect(runCLI("version=5.0.0-rc.1", "tarball_name=release.tgz").ExitCode()).To(Equal(0))
+
+		summary := loadSummary()
+		Expect(summary).To(HaveKeyWithValue("version", "5.0.0-rc.1"))
+		Expect(summary).To(HaveKeyWithValue("tarball", "release.tgz"))
+
+		_, err := os.Stat(filepath.Join(tmpdir, "release.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("uncommitted changes", func() {
+		BeforeEach(func() {
+			Expect(testing.RunCommands(releasedir, []string{"bosh generate-job uncommitted"})).To(Succeed())
+		})
+
+		It("fails by default", func() {
+			Expect(runCLI().ExitCode()).NotTo(Equal(0))
+		})
+
+		It("includes them when requested", func() {
+			Expect(runCLI("dirty=true").ExitCode()).To(Equal(0))
+
+			summary := loadSummary()
+			Expect(summary).To(HaveKeyWithValue("dirty", true))
+			Expect(summary["version"]).To(MatchRegexp(`\.commit\.[0-9a-f]+\.dirty\.[0-9a-f]{12}$`))
+		})
+
+		It("uses the same version for the same changes", func() {
+			Expect(runCLI("dirty=true").ExitCode()).To(Equal(0))
+			firstVersion := loadSummary()["version"]
+
+			Expect(runCLI("dirty=true").ExitCode()).To(Equal(0))
+			Expect(loadSummary()["version"]).To(Equal(firstVersion))
+
+			Expect(testing.RunCommands(releasedir, []string{"echo changed >> jobs/uncommitted/spec"})).To(Succeed())
+
+			Expect(runCLI("dirty=true").ExitCode()).To(Equal(0))
+			Expect(loadSummary()["version"]).NotTo(Equal(firstVersion))
+		})
+	})
+})
@@ -20,6 +20,7 @@ type Request struct {
 type Params struct {
 	TarballName string `json:"tarball_name"`
 	Tarball     bool   `json:"tarball"`
+	JobsJSON    bool   `json:"jobs_json"`
 }
 
 type Response struct {
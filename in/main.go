@@ -10,6 +10,7 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/dpb587/bosh-release-resource/api"
 	"github.com/dpb587/bosh-release-resource/boshrelease"
 	"github.com/pkg/errors"
@@ -34,14 +35,14 @@ func main() {
 		api.Fatal(errors.Wrap(err, "bad config: file_name"))
 	}
 
-	repository := boshrelease.NewRepository(request.Source.URI, request.Source.Branch, request.Source.PrivateKey)
+	repository := request.Source.Repository()
 
 	err = repository.Pull()
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad repository: pulling"))
 	}
 
-	release := boshrelease.NewRelease(repository, request.Source.PrivateConfig)
+	release := request.Source.Release(repository)
 
 	releaseName := request.Source.Name
 
@@ -73,14 +74,22 @@ func main() {
 			f = release.CreateTarball
 		}
 
+		tarballPath := filepath.Join(destination, tarballNameBuffer.String())
+
 		err = f(
 			releaseName,
 			request.Version.Version,
-			filepath.Join(destination, tarballNameBuffer.String()),
+			tarballPath,
 		)
 		if err != nil {
 			api.Fatal(errors.Wrap(err, "bad release"))
 		}
+
+		verifyTarball(request, release, releaseName, tarballPath)
+	}
+
+	if request.Params.JobsJSON {
+		writeJobsJSON(destination, request, release, releaseName)
 	}
 
 	err = ioutil.WriteFile(filepath.Join(destination, "name"), []byte(releaseName), 0644)
@@ -93,20 +102,101 @@ func main() {
 		api.Fatal(errors.Wrap(err, "fs metadata: version"))
 	}
 
-	err = json.NewEncoder(os.Stdout).Encode(Response{
-		Version: request.Version,
-		Metadata: []api.Metadata{
-			{
-				Name:  "bosh",
-				Value: boshrelease.BoshVersion(),
-			},
-			{
-				Name:  "time",
-				Value: time.Now().Format(time.RFC3339),
-			},
+	err = repository.Close()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: closing"))
+	}
+
+	metadata := []api.Metadata{
+		{
+			Name:  "bosh",
+			Value: boshrelease.BoshVersion(),
+		},
+		{
+			Name:  "time",
+			Value: time.Now().Format(time.RFC3339),
 		},
+	}
+
+	if remote := repository.Remote(); remote != "" {
+		metadata = append(metadata, api.Metadata{
+			Name:  "uri",
+			Value: remote,
+		})
+	}
+
+	err = json.NewEncoder(os.Stdout).Encode(Response{
+		Version:  request.Version,
+		Metadata: metadata,
 	})
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad stdout: json"))
 	}
 }
+
+// verifyTarball confirms the created tarball is of the requested version and
+// commit (when known), and that it is not corrupt.
+func verifyTarball(request Request, release *boshrelease.Release, name, tarballPath string) {
+	expectedCommit := loadCommitHash(request, release, name)
+
+	tarball, err := boshrelease.OpenReleaseTarball(tarballPath)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release tarball: reading"))
+	}
+
+	err = tarball.VerifyRelease(name, request.Version.Version, expectedCommit, false)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release tarball"))
+	}
+}
+
+// loadCommitHash returns the commit the version was created from, which may be
+// empty for final versions which did not record it.
+func loadCommitHash(request Request, release *boshrelease.Release, name string) string {
+	if request.Source.DevReleases {
+		version, err := semver.NewVersion(request.Version.Version)
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad version: parsing"))
+		}
+
+		commit, err := boshrelease.DevVersionCommit(version)
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad version"))
+		}
+
+		return commit
+	}
+
+	manifest, err := release.Manifest(name, request.Version.Version)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release"))
+	}
+
+	return manifest.CommitHash
+}
+
+func writeJobsJSON(destination string, request Request, release *boshrelease.Release, name string) {
+	commit := loadCommitHash(request, release, name)
+	if commit == "" {
+		api.Fatal(errors.New("bad release: commit_hash is unknown"))
+	}
+
+	specs, err := release.JobSpecs(commit)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release: loading job specs"))
+	}
+
+	if specs == nil {
+		specs = []boshrelease.JobSpec{}
+	}
+
+	jobsBytes, err := json.MarshalIndent(specs, "", "  ")
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad job specs: json"))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(destination, "jobs.json"), append(jobsBytes, '\n'), 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "fs metadata: jobs.json"))
+	}
+}
@@ -13,6 +13,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/dpb587/bosh-release-resource/internal/testing"
 	"github.com/onsi/gomega/gexec"
 )
 
@@ -154,4 +155,53 @@ var _ = Describe("Main", func() {
 			})
 		})
 	})
+	Context("generated repositories", func() {
+		var releasedir string
+
+		BeforeEach(func() {
+			var err error
+
+			releasedir, err = testing.GenerateRelease()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(releasedir)).To(Succeed())
+		})
+
+		It("writes job specs", func() {
+			command := exec.Command(cli, tmpdir)
+			command.Stdin = bytes.NewBufferString(fmt.Sprintf(`{
+	"source": {
+		"uri": "%s"
+	},
+	"version": {
+		"version": "1.1.0"
+	},
+	"params": {
+		"tarball": false,
+		"jobs_json": true
+	}
+}`, releasedir))
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			session.Wait(time.Minute)
+			Expect(session.ExitCode()).To(Equal(0))
+
+			data, err := ioutil.ReadFile(path.Join(tmpdir, "jobs.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var jobs []map[string]interface{}
+
+			Expect(json.Unmarshal(data, &jobs)).To(Succeed())
+			Expect(jobs).To(HaveLen(2))
+			Expect(jobs[0]).To(HaveKeyWithValue("name", "fake1"))
+			Expect(jobs[1]).To(HaveKeyWithValue("name", "fake2"))
+
+			_, err = os.Stat(path.Join(tmpdir, "fake-1.1.0.tgz"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,57 @@
+package testing
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StartGitHTTPServer serves the repositories of root over the git smart HTTP
+// protocol, requiring basic authentication if a username is configured.
+func StartGitHTTPServer(root, username, password string) (*httptest.Server, error) {
+	handler, err := NewGitHTTPHandler(root, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return httptest.NewServer(handler), nil
+}
+
+func NewGitHTTPHandler(root, username, password string) (http.Handler, error) {
+	stdout := &bytes.Buffer{}
+
+	cmd := exec.Command("git", "--exec-path")
+	cmd.Stdout = stdout
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.Wrap(err, "finding git exec path")
+	}
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(stdout.String()), "git-http-backend"),
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + root,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username != "" {
+			if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		backend.ServeHTTP(w, r)
+	}), nil
+}
@@ -0,0 +1,41 @@
+package testing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateRemote creates a directory with a bare repository, name, which may
+// be served over HTTP and whose master branch has a single commit of `file`
+// (with contents `one`) pushed from a clone in `work`. Additional commands are
+// then run from the directory.
+func GenerateRemote(name string, commands ...string) (string, error) {
+	serverdir, err := ioutil.TempDir("", "bosh-release-resource-git-server")
+	if err != nil {
+		return "", err
+	}
+
+	err = RunCommands(
+		serverdir,
+		append(
+			[]string{
+				fmt.Sprintf("git init --bare %s", name),
+				fmt.Sprintf("git -C %s config http.receivepack true", name),
+				fmt.Sprintf("git clone %s work", name),
+				"cd work && echo one > file && git add file && git -c user.name=test -c user.email=test@localhost commit -m one && git push origin HEAD:master",
+			},
+			commands...,
+		),
+	)
+
+	if err != nil {
+		os.RemoveAll(serverdir)
+
+		return "", errors.Wrap(err, "generating remote")
+	}
+
+	return serverdir, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/dpb587/bosh-release-resource/api"
+	"github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/releasenotes"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		api.Fatal(errors.Wrap(fmt.Errorf("%s NOTES-PATH [VERSION]", os.Args[0]), "load-release-notes: bad invocation"))
+	}
+
+	notesPath := os.Args[1]
+	version := loadVersion()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: working directory"))
+	}
+
+	repository := boshrelease.NewLocalRepository(cwd)
+	release := boshrelease.NewRelease(repository, nil)
+
+	releaseName := os.Getenv("name")
+
+	if releaseName == "" {
+		releaseName, err = release.Name()
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad release: discovering name"))
+		}
+	}
+
+	notesRelease := releasenotes.Release{
+		Name:    releaseName,
+		Version: version,
+		Tags:    loadTags(releaseName, version),
+	}
+
+	for _, source := range loadSources(repository) {
+		fmt.Fprintf(os.Stderr, "checking %s...\n", source)
+
+		notes, err := source.Find(notesRelease)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+
+			continue
+		} else if notes == nil {
+			continue
+		}
+
+		writeNotes(notesPath, notes)
+
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "no release notes found")
+
+	if os.Getenv("ignore_missing") == "true" {
+		writeNotes(notesPath, nil)
+
+		return
+	}
+
+	os.Exit(1)
+}
+
+func loadVersion() string {
+	if version := os.Getenv("version"); version != "" {
+		return version
+	}
+
+	if len(os.Args) < 3 {
+		api.Fatal(errors.New("bad args: version is required"))
+	}
+
+	version := os.Args[2]
+
+	if _, err := os.Stat(version); err == nil {
+		versionBytes, err := ioutil.ReadFile(version)
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad version: reading"))
+		}
+
+		version = strings.TrimSpace(string(versionBytes))
+	}
+
+	return version
+}
+
+func loadTags(name, version string) []string {
+	tagName := os.Getenv("tag_name")
+	if tagName == "" {
+		tagName = api.DefaultTagName
+	}
+
+	tagNameTmpl, err := template.New("tag_name").Parse(tagName)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad config: tag_name"))
+	}
+
+	tag, err := api.ExecuteTagTemplate(tagNameTmpl, api.TagTemplateData{
+		Name:    name,
+		Version: version,
+	})
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad config: generating tag name"))
+	}
+
+	if tag == version {
+		return []string{tag}
+	}
+
+	return []string{tag, version}
+}
+
+func loadSources(repository *boshrelease.Repository) []releasenotes.NotesSource {
+	var sources []releasenotes.NotesSource
+
+	if os.Getenv("skip_local") != "true" {
+		sources = append(sources, releasenotes.LocalSource{Dir: repository.Path()})
+	}
+
+	var remotes []string
+
+	if os.Getenv("skip_github") != "true" || os.Getenv("skip_gitlab") != "true" {
+		var err error
+
+		remotes, err = repository.RemoteURLs()
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad repository: loading remotes"))
+		}
+	}
+
+	if os.Getenv("skip_github") != "true" {
+		githubAPI := os.Getenv("GITHUB_API")
+		githubHost := strings.TrimPrefix(hostOf(githubAPI, releasenotes.DefaultGitHubBaseURL), "api.")
+
+		for _, path := range matchRemotes(remotes, githubHost) {
+			pathSplit := strings.SplitN(path, "/", 2)
+			if len(pathSplit) != 2 {
+				continue
+			}
+
+			sources = append(sources, releasenotes.GitHubSource{
+				BaseURL: githubAPI,
+				Token:   os.Getenv("GITHUB_TOKEN"),
+				Owner:   pathSplit[0],
+				Repo:    pathSplit[1],
+			})
+		}
+	}
+
+	if os.Getenv("skip_gitlab") != "true" {
+		gitlabAPI := os.Getenv("GITLAB_API")
+		gitlabHost := hostOf(gitlabAPI, releasenotes.DefaultGitLabBaseURL)
+
+		for _, path := range matchRemotes(remotes, gitlabHost) {
+			sources = append(sources, releasenotes.GitLabSource{
+				BaseURL: gitlabAPI,
+				Token:   os.Getenv("GITLAB_TOKEN"),
+				Project: path,
+			})
+		}
+	}
+
+	if os.Getenv("skip_tag") != "true" {
+		sources = append(sources, releasenotes.GitTagSource{Repository: repository})
+	}
+
+	return sources
+}
+
+func hostOf(baseURL, defaultBaseURL string) string {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		api.Fatal(errors.Wrapf(err, "bad config: parsing %s", baseURL))
+	}
+
+	return parsed.Hostname()
+}
+
+func matchRemotes(remotes []string, host string) []string {
+	var paths []string
+	var seen = map[string]bool{}
+
+	for _, remote := range remotes {
+		remoteHost, remotePath, ok := boshrelease.ParseRemote(remote)
+		if !ok || remoteHost != host || seen[remotePath] {
+			continue
+		}
+
+		seen[remotePath] = true
+		paths = append(paths, remotePath)
+	}
+
+	return paths
+}
+
+func writeNotes(path string, notes []byte) {
+	err := ioutil.WriteFile(path, notes, 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "writing release notes"))
+	}
+}
@@ -0,0 +1,103 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Main", func() {
+	var releasedir, tmpdir string
+
+	BeforeEach(func() {
+		var err error
+
+		releasedir, err = ioutil.TempDir("", "bosh-release-load-release-notes-repo")
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpdir, err = ioutil.TempDir("", "bosh-release-load-release-notes")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(testing.RunCommands(releasedir, []string{
+			"git init .",
+			"mkdir config && echo 'name: fake' > config/final.yml",
+			"git add . && git -c user.name=test -c user.email=test@localhost commit -m init",
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(releasedir)).To(Succeed())
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	runCLI := func(args []string, env ...string) *gexec.Session {
+		command := exec.Command(cli, append([]string{filepath.Join(tmpdir, "notes.md")}, args...)...)
+		command.Dir = releasedir
+		command.Env = append(os.Environ(), append([]string{"skip_github=true", "skip_gitlab=true"}, env...)...)
+
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		session.Wait(time.Minute)
+
+		return session
+	}
+
+	loadNotes := func() string {
+		notesBytes, err := ioutil.ReadFile(filepath.Join(tmpdir, "notes.md"))
+		Expect(err).NotTo(HaveOccurred())
+
+		return string(notesBytes)
+	}
+
+	It("prefers local release notes", func() {
+		Expect(testing.RunCommands(releasedir, []string{
+			"mkdir -p releases/fake && echo 'local notes' > releases/fake/fake-1.0.0.md",
+			"git -c user.name=test -c user.email=test@localhost tag -a -m 'tag notes' v1.0.0",
+		})).To(Succeed())
+
+		Expect(runCLI([]string{"1.0.0"}).ExitCode()).To(Equal(0))
+		Expect(loadNotes()).To(Equal("local notes\n"))
+	})
+
+	It("falls back to annotated tags", func() {
+		Expect(testing.RunCommands(releasedir, []string{
+			"git -c user.name=test -c user.email=test@localhost tag -a -m 'tag notes' v1.0.0",
+		})).To(Succeed())
+
+		Expect(runCLI([]string{"1.0.0"}).ExitCode()).To(Equal(0))
+		Expect(loadNotes()).To(Equal("tag notes"))
+	})
+
+	It("reads the version from a file", func() {
+		Expect(testing.RunCommands(releasedir, []string{
+			"git -c user.name=test -c user.email=test@localhost tag -a -m 'tag notes' release-1.0.0",
+		})).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpdir, "version"), []byte("1.0.0\n"), 0644)).To(Succeed())
+
+		Expect(runCLI([]string{filepath.Join(tmpdir, "version")}, "tag_name=release-{{.Version}}").ExitCode()).To(Equal(0))
+		Expect(loadNotes()).To(Equal("tag notes"))
+	})
+
+	Describe("missing release notes", func() {
+		It("fails by default", func() {
+			Expect(runCLI([]string{"1.0.0"}).ExitCode()).NotTo(Equal(0))
+
+			_, err := os.Stat(filepath.Join(tmpdir, "notes.md"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("writes empty release notes when ignored", func() {
+			Expect(runCLI([]string{"1.0.0"}, "ignore_missing=true").ExitCode()).To(Equal(0))
+			Expect(loadNotes()).To(Equal(""))
+		})
+	})
+})
@@ -21,11 +21,28 @@ type Params struct {
 	Repository string `json:"repository,omitempty"`
 	Version    string `json:"version"`
 
+	Releases        []ReleaseParams `json:"releases,omitempty"`
+	SeparateCommits bool            `json:"separate_commits,omitempty"`
+
 	CommitFile  string `json:"commit_file,omitempty"`
 	AuthorName  string `json:"author_name,omitempty"`
 	AuthorEmail string `json:"author_email,omitempty"`
 	Rebase      bool   `json:"rebase,omitempty"`
 	SkipTag     bool   `json:"skip_tag,omitempty"`
+
+	ReleaseNotes bool `json:"release_notes,omitempty"`
+
+	TagMessage      string `json:"tag_message,omitempty"`
+	TagReleaseNotes bool   `json:"tag_release_notes,omitempty"`
+	TagLightweight  bool   `json:"tag_lightweight,omitempty"`
+}
+
+// ReleaseParams describes one of several releases to finalize together.
+type ReleaseParams struct {
+	Tarball    string `json:"tarball,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Version    string `json:"version"`
+	Name       string `json:"name,omitempty"`
 }
 
 type Response struct {
@@ -5,15 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/dpb587/bosh-release-resource/api"
 	"github.com/dpb587/bosh-release-resource/boshrelease"
 	"github.com/pkg/errors"
-	yaml "gopkg.in/yaml.v2"
 )
 
 func main() {
@@ -33,74 +32,214 @@ func main() {
 		api.Fatal(errors.New("bad source: branch is required"))
 	}
 
-	version := loadVersion(request)
-	commitMessage := loadCommitMessage(request, version)
+	if request.Source.LocalRepository != "" {
+		api.Fatal(errors.New("bad source: local_repository is read-only"))
+	}
+
+	releaseParams := loadReleaseParams(request)
+
+	if request.Params.CommitFile != "" && request.Params.SeparateCommits && len(releaseParams) > 1 {
+		api.Fatal(errors.New("bad params: commit_file cannot be used with separate_commits"))
+	}
 
-	repository := boshrelease.NewRepository(request.Source.URI, request.Source.Branch, request.Source.PrivateKey)
+	versions := make([]string, len(releaseParams))
+
+	for releaseIdx, params := range releaseParams {
+		versions[releaseIdx] = loadVersion(params.Version)
+	}
+
+	tagMessageTmpl, err := template.New("tag_message").Parse(request.Params.TagMessage)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad params: tag_message"))
+	}
+
+	repository := request.Source.Repository()
 
 	err = repository.Pull()
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad repository: pulling"))
 	}
 
-	release := boshrelease.NewRelease(repository, request.Source.PrivateConfig)
+	release := request.Source.Release(repository)
 
-	releaseName := request.Source.Name
+	releaseNames := make([]string, len(releaseParams))
+	seen := map[string]bool{}
 
-	if releaseName == "" {
-		releaseName, err = release.Name()
-		if err != nil {
-			api.Fatal(errors.Wrap(err, "bad release: discovering name"))
+	for releaseIdx, params := range releaseParams {
+		releaseName := params.Name
+
+		if releaseName == "" {
+			releaseName = request.Source.Name
 		}
-	}
 
-	tarballPath := loadTarballPath(request, release)
+		if releaseName == "" {
+			releaseName, err = release.Name()
+			if err != nil {
+				api.Fatal(errors.Wrap(err, "bad release: discovering name"))
+			}
+		}
+
+		key := fmt.Sprintf("%s/%s", releaseName, versions[releaseIdx])
+		if seen[key] {
+			api.Fatal(fmt.Errorf("bad params: release %s is configured more than once", key))
+		}
+
+		seen[key] = true
+		releaseNames[releaseIdx] = releaseName
+	}
 
 	err = repository.Configure(request.Params.AuthorName, request.Params.AuthorEmail)
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "configuring"))
 	}
 
-	versionCommitHash, err := release.FinalizeRelease(releaseName, version, tarballPath)
-	if err != nil {
-		api.Fatal(errors.Wrap(err, "bad release tarball"))
+	// everything is finalized and committed locally before anything is pushed
+	versionCommitHashes := make([]string, len(releaseParams))
+
+	for releaseIdx, params := range releaseParams {
+		releaseName := releaseNames[releaseIdx]
+		version := versions[releaseIdx]
+
+		tarballPath := loadTarballPath(params, release)
+
+		// tarballs created from a repository checkout are forced, so only
+		// provided tarballs are required to be committed
+		verifyTarball(tarballPath, releaseName, version, params.Repository == "")
+
+		versionCommitHashes[releaseIdx], err = release.FinalizeRelease(releaseName, version, tarballPath)
+		if err != nil {
+			api.Fatal(errors.Wrapf(err, "bad release tarball: %s/%s", releaseName, version))
+		}
+
+		if request.Params.ReleaseNotes {
+			writeReleaseNotes(repository, release, releaseName, version)
+		}
+
+		if request.Params.SeparateCommits {
+			_, err = repository.Commit(loadCommitMessage(request, releaseNames[releaseIdx:releaseIdx+1], versions[releaseIdx:releaseIdx+1]))
+			if err != nil {
+				api.Fatal(errors.Wrap(err, "bad commit"))
+			}
+		}
+	}
+
+	if !request.Params.SeparateCommits {
+		_, err = repository.Commit(loadCommitMessage(request, releaseNames, versions))
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad commit"))
+		}
+	}
+
+	var tagFn boshrelease.TagFunc
+
+	if !request.Params.SkipTag {
+		tagFn = func(commits []string) ([]boshrelease.Tag, error) {
+			if request.Params.SeparateCommits && len(commits) != len(releaseParams) {
+				return nil, fmt.Errorf("expected %d commits but found %d", len(releaseParams), len(commits))
+			}
+
+			var tags []boshrelease.Tag
+
+			for releaseIdx := range releaseParams {
+				commit := commits[len(commits)-1]
+
+				if request.Params.SeparateCommits {
+					commit = commits[releaseIdx]
+				}
+
+				tagData := api.TagTemplateData{
+					Name:       releaseNames[releaseIdx],
+					Version:    versions[releaseIdx],
+					Commit:     commit,
+					CommitHash: versionCommitHashes[releaseIdx],
+				}
+
+				tag, err := api.ExecuteTagTemplate(request.Source.TagNameTemplate, tagData)
+				if err != nil {
+					return nil, errors.Wrap(err, "generating tag name")
+				}
+
+				var tagMessage string
+
+				if !request.Params.TagLightweight {
+					tagMessage, err = loadTagMessage(request, repository, tagMessageTmpl, tag, tagData)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				tags = append(tags, boshrelease.Tag{
+					Name:    tag,
+					Commit:  versionCommitHashes[releaseIdx],
+					Message: tagMessage,
+				})
+			}
+
+			return tags, nil
+		}
 	}
 
-	commit, err := repository.Commit(commitMessage, request.Params.Rebase)
+	commits, err := repository.Push(request.Params.Rebase, tagFn)
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad commit"))
 	}
 
-	if !request.Params.SkipTag {
-		tag := fmt.Sprintf("v%s", version)
-		err = repository.Tag(versionCommitHash, tag, tag)
-		if err != nil {
-			api.Fatal(errors.Wrap(err, "bad tag"))
+	err = repository.Close()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: closing"))
+	}
+
+	metadata := []api.Metadata{
+		{
+			Name:  "bosh",
+			Value: boshrelease.BoshVersion(),
+		},
+		{
+			Name:  "commit",
+			Value: commits[len(commits)-1],
+		},
+	}
+
+	if len(releaseParams) > 1 {
+		for releaseIdx := range releaseParams {
+			metadata = append(metadata, api.Metadata{
+				Name:  "release",
+				Value: fmt.Sprintf("%s/%s", releaseNames[releaseIdx], versions[releaseIdx]),
+			})
 		}
 	}
 
 	err = json.NewEncoder(os.Stdout).Encode(Response{
 		Version: api.Version{
-			Version: version,
-		},
-		Metadata: []api.Metadata{
-			{
-				Name:  "bosh",
-				Value: boshrelease.BoshVersion(),
-			},
-			{
-				Name:  "commit",
-				Value: commit,
-			},
+			Version: versions[0],
 		},
+		Metadata: metadata,
 	})
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad stdout: json"))
 	}
 }
 
-func loadVersion(request Request) string {
-	versionPaths, err := filepath.Glob(request.Params.Version)
+// loadReleaseParams returns the releases to finalize, either from the releases
+// list or the single release of the top-level params.
+func loadReleaseParams(request Request) []ReleaseParams {
+	single := ReleaseParams{
+		Tarball:    request.Params.Tarball,
+		Repository: request.Params.Repository,
+		Version:    request.Params.Version,
+	}
+
+	if len(request.Params.Releases) == 0 {
+		return []ReleaseParams{single}
+	} else if single != (ReleaseParams{}) {
+		api.Fatal(errors.New("bad params: only releases or tarball, repository, and version may be configured"))
+	}
+
+	return request.Params.Releases
+}
+
+func loadVersion(versionPath string) string {
+	versionPaths, err := filepath.Glob(versionPath)
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad params: globbing version"))
 	} else if len(versionPaths) == 0 {
@@ -117,8 +256,22 @@ func loadVersion(request Request) string {
 	return strings.TrimSpace(string(versionBytes))
 }
 
-func loadCommitMessage(request Request, version string) string {
-	var commitMessage = fmt.Sprintf("Version %s", version)
+func loadCommitMessage(request Request, names, versions []string) string {
+	var commitMessage = fmt.Sprintf("Version %s", strings.Join(versions, ", "))
+
+	for _, name := range names[1:] {
+		if name != names[0] {
+			var releases []string
+
+			for releaseIdx := range names {
+				releases = append(releases, fmt.Sprintf("%s/%s", names[releaseIdx], versions[releaseIdx]))
+			}
+
+			commitMessage = fmt.Sprintf("Version %s", strings.Join(releases, ", "))
+
+			break
+		}
+	}
 
 	if request.Params.CommitFile != "" {
 		commitFilePaths, err := filepath.Glob(request.Params.CommitFile)
@@ -141,33 +294,77 @@ func loadCommitMessage(request Request, version string) string {
 	return commitMessage
 }
 
-func loadTarballPath(request Request, release *boshrelease.Release) string {
-	if request.Params.Tarball != "" && request.Params.Repository != "" {
-		api.Fatal(errors.New("bad params: only tarball or repository may be configured"))
-	} else if request.Params.Repository != "" {
-		if request.Source.PrivateConfig != nil {
-			privateYmlBytes, err := yaml.Marshal(request.Source.PrivateConfig)
-			if err != nil {
-				api.Fatal(errors.Wrap(err, "marshalling private.yml"))
-			}
+func writeReleaseNotes(repository *boshrelease.Repository, release *boshrelease.Release, name, version string) {
+	notesPath := path.Join(repository.Path(), "releases", name, fmt.Sprintf("%s-%s.md", name, version))
 
-			err = ioutil.WriteFile(path.Join(request.Params.Repository, "config", "private.yml"), privateYmlBytes, 0700)
-			if err != nil {
-				api.Fatal(errors.Wrap(err, "writing private.yml"))
+	if _, err := os.Stat(notesPath); err == nil {
+		// respect notes which were already committed
+		return
+	}
+
+	notes, err := release.GenerateReleaseNotes(name, version)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release notes: generating"))
+	}
+
+	err = ioutil.WriteFile(notesPath, append(notes, '\n'), 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release notes: writing"))
+	}
+}
+
+func loadTagMessage(request Request, repository *boshrelease.Repository, tagMessageTmpl *template.Template, tag string, data api.TagTemplateData) (string, error) {
+	if request.Params.TagReleaseNotes {
+		notesBytes, err := ioutil.ReadFile(path.Join(repository.Path(), "releases", data.Name, fmt.Sprintf("%s-%s.md", data.Name, data.Version)))
+		if err == nil {
+			if notes := strings.TrimSpace(string(notesBytes)); notes != "" {
+				return notes, nil
 			}
+		} else if !os.IsNotExist(err) {
+			return "", errors.Wrap(err, "reading release notes")
 		}
+	}
+
+	if request.Params.TagMessage == "" {
+		return tag, nil
+	}
+
+	tagMessage, err := api.ExecuteTagTemplate(tagMessageTmpl, data)
+	if err != nil {
+		return "", errors.Wrap(err, "generating tag message")
+	}
+
+	return tagMessage, nil
+}
 
-		tarballPath, err := filepath.Abs(path.Join(request.Params.Repository, "release.tgz"))
+// verifyTarball rejects tarballs which would not finalize as the expected
+// release and version, or which are corrupt.
+func verifyTarball(tarballPath, name, version string, requireCommitted bool) {
+	tarball, err := boshrelease.OpenReleaseTarball(tarballPath)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release tarball: reading"))
+	}
+
+	if requireCommitted && tarball.Manifest().UncommittedChanges {
+		api.Fatal(errors.New("bad release tarball: created with uncommitted changes"))
+	}
+
+	err = tarball.VerifyRelease(name, version, "", true)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release tarball"))
+	}
+}
+
+func loadTarballPath(params ReleaseParams, release *boshrelease.Release) string {
+	if params.Tarball != "" && params.Repository != "" {
+		api.Fatal(errors.New("bad params: only tarball or repository may be configured"))
+	} else if params.Repository != "" {
+		tarballPath, err := filepath.Abs(path.Join(params.Repository, "release.tgz"))
 		if err != nil {
 			api.Fatal(errors.Wrap(err, "making absolute path"))
 		}
 
-		cmd := exec.Command("bosh", "create-release", "--force", "--tarball", tarballPath)
-		cmd.Dir = request.Params.Repository
-		cmd.Stdout = os.Stderr
-		cmd.Stderr = os.Stderr
-
-		err = cmd.Run()
+		err = release.CreateCheckoutTarball(params.Repository, tarballPath)
 		if err != nil {
 			api.Fatal(errors.Wrap(err, "bad repository: creating release"))
 		}
@@ -175,7 +372,7 @@ func loadTarballPath(request Request, release *boshrelease.Release) string {
 		return tarballPath
 	}
 
-	tarballPaths, err := filepath.Glob(request.Params.Tarball)
+	tarballPaths, err := filepath.Glob(params.Tarball)
 	if err != nil {
 		api.Fatal(errors.Wrap(err, "bad params: globbing tarball"))
 	} else if len(tarballPaths) == 0 {
@@ -155,5 +155,116 @@ var _ = Describe("Main", func() {
 				Expect(taggedCommit).To(Equal(forkCommit))
 			})
 		})
+
+		It("supports tag templates", func() {
+			runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "%s",
+			"branch": "master",
+			"tag_name": "{{.Name}}/v{{.Version}}"
+		},
+		"params": {
+			"repository": "%s",
+			"version": "%s",
+			"tag_message": "Release {{.Name}} {{.Version}} ({{.CommitHash}})"
+		}
+	}`, releasedir, forkdir, versionfile))
+
+			forkCommit, err := testing.RunCommandStdout(forkdir, "git", "rev-parse", "HEAD")
+			Expect(err).NotTo(HaveOccurred())
+
+			taggedCommit, err := testing.RunCommandStdout(releasedir, "git", "rev-parse", "fake/v6.3.1^{}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taggedCommit).To(Equal(forkCommit))
+
+			tagMessage, err := testing.RunCommandStdout(releasedir, "git", "tag", "-l", "--format=%(contents)", "fake/v6.3.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(tagMessage)).To(Equal(fmt.Sprintf("Release fake 6.3.1 (%s)", forkCommit[0:7])))
+		})
+
+		It("generates release notes", func() {
+			runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "%s",
+			"branch": "master"
+		},
+		"params": {
+			"repository": "%s",
+			"version": "%s",
+			"release_notes": true,
+			"tag_release_notes": true
+		}
+	}`, releasedir, forkdir, versionfile))
+
+			notesBytes, err := ioutil.ReadFile(path.Join(releasedir, "releases/fake/fake-6.3.1.md"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(notesBytes)).To(ContainSubstring(" fake12\n"))
+			Expect(string(notesBytes)).To(ContainSubstring(" * added `fake12`\n"))
+
+			tagMessage, err := testing.RunCommandStdout(releasedir, "git", "tag", "-l", "--format=%(contents)", "v6.3.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(tagMessage)).To(Equal(strings.TrimSpace(string(notesBytes))))
+		})
+
+		It("supports lightweight tags", func() {
+			runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "%s",
+			"branch": "master"
+		},
+		"params": {
+			"repository": "%s",
+			"version": "%s",
+			"tag_lightweight": true
+		}
+	}`, releasedir, forkdir, versionfile))
+
+			tagType, err := testing.RunCommandStdout(releasedir, "git", "cat-file", "-t", "v6.3.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(tagType)).To(Equal("commit"))
+		})
+
+		It("finalizes several releases", func() {
+			nextversion, err := ioutil.TempFile("", "bosh-release-resource-version-file")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(nextversion.Name())
+
+			_, err = nextversion.WriteString("6.4.0")
+			Expect(err).NotTo(HaveOccurred())
+
+			result := runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "%s",
+			"branch": "master"
+		},
+		"params": {
+			"releases": [
+				{
+					"repository": "%s",
+					"version": "%s"
+				},
+				{
+					"repository": "%s",
+					"version": "%s"
+				}
+			],
+			"separate_commits": true
+		}
+	}`, releasedir, forkdir, versionfile, forkdir, nextversion.Name()))
+			Expect(result["version"].(map[string]interface{})["version"]).To(Equal("6.3.1"))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(map[string]interface{}{
+				"name":  "release",
+				"value": "fake/6.4.0",
+			}))
+
+			subjects, err := testing.RunCommandStdout(releasedir, "git", "log", "-n2", "--format=%s")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subjects).To(Equal("Version 6.4.0\nVersion 6.3.1\n"))
+
+			for _, tag := range []string{"v6.3.1", "v6.4.0"} {
+				_, err := testing.RunCommandStdout(releasedir, "git", "rev-parse", tag)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
 	})
 })
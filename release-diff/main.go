@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dpb587/bosh-release-resource/api"
+	"github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	if len(os.Args) < 4 {
+		api.Fatal(errors.Wrap(fmt.Errorf("%s OUTPUT-DIR FROM-VERSION-PATH TO-VERSION-PATH", os.Args[0]), "release-diff: bad invocation"))
+	}
+
+	outputDir := os.Args[1]
+	fromVersion := loadVersion(os.Args[2])
+	toVersion := loadVersion(os.Args[3])
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad repository: working directory"))
+	}
+
+	repository := boshrelease.NewLocalRepository(cwd)
+	release := boshrelease.NewRelease(repository, nil)
+
+	releaseName := os.Getenv("name")
+
+	if releaseName == "" {
+		releaseName, err = release.Name()
+		if err != nil {
+			api.Fatal(errors.Wrap(err, "bad release: discovering name"))
+		}
+	}
+
+	diff, err := release.Diff(releaseName, fromVersion, toVersion)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad release: comparing versions"))
+	}
+
+	jsonBytes, err := diff.JSON()
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad diff: json"))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(outputDir, "release-diff.json"), append(jsonBytes, '\n'), 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad output: writing release-diff.json"))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(outputDir, "release-diff.md"), diff.Markdown(), 0644)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad output: writing release-diff.md"))
+	}
+}
+
+// loadVersion returns the version from the contents of a file.
+func loadVersion(versionPath string) string {
+	versionBytes, err := ioutil.ReadFile(versionPath)
+	if err != nil {
+		api.Fatal(errors.Wrap(err, "bad version: reading"))
+	}
+
+	return strings.TrimSpace(string(versionBytes))
+}
@@ -0,0 +1,102 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Main", func() {
+	var releasedir, tmpdir string
+
+	writeFile := func(dir, name, contents string) {
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)).To(Succeed())
+	}
+
+	commit := func(message string) string {
+		Expect(testing.RunCommands(releasedir, []string{
+			fmt.Sprintf("git add . && git -c user.name=test -c user.email=test@localhost commit -m %s", message),
+		})).To(Succeed())
+
+		stdout, err := testing.RunCommandStdout(releasedir, "git", "rev-parse", "--short", "HEAD")
+		Expect(err).NotTo(HaveOccurred())
+
+		return strings.TrimSpace(stdout)
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		releasedir, err = ioutil.TempDir("", "bosh-release-release-diff-repo")
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpdir, err = ioutil.TempDir("", "bosh-release-release-diff")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(testing.RunCommands(releasedir, []string{"git init ."})).To(Succeed())
+
+		writeFile(releasedir, "config/final.yml", "name: fake\n")
+		writeFile(releasedir, "jobs/fake1/spec", "name: fake1\n")
+		first := commit("one")
+
+		writeFile(releasedir, "jobs/fake1/spec", "name: fake1\nproperties:\n  port:\n    default: 8080\n")
+		second := commit("two")
+
+		writeFile(releasedir, "releases/fake/fake-1.0.0.yml", fmt.Sprintf("name: fake\nversion: 1.0.0\ncommit_hash: %s\njobs:\n- name: fake1\n  fingerprint: aaa111\n  sha1: x\npackages: []\n", first))
+		writeFile(releasedir, "releases/fake/fake-1.1.0.yml", fmt.Sprintf("name: fake\nversion: 1.1.0\ncommit_hash: %s\njobs:\n- name: fake1\n  fingerprint: bbb222\n  sha1: x\npackages: []\n", second))
+		commit("finalize")
+
+		writeFile(tmpdir, "from/version", "1.0.0\n")
+		writeFile(tmpdir, "to/version", "1.1.0\n")
+		Expect(os.MkdirAll(filepath.Join(tmpdir, "output"), 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(releasedir)).To(Succeed())
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	runCLI := func(args ...string) *gexec.Session {
+		command := exec.Command(cli, args...)
+		command.Dir = releasedir
+
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		session.Wait(time.Minute)
+
+		return session
+	}
+
+	It("writes the diff as JSON and Markdown", func() {
+		Expect(runCLI(filepath.Join(tmpdir, "output"), filepath.Join(tmpdir, "from", "version"), filepath.Join(tmpdir, "to", "version")).ExitCode()).To(Equal(0))
+
+		jsonBytes, err := ioutil.ReadFile(filepath.Join(tmpdir, "output", "release-diff.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var diff map[string]interface{}
+		Expect(json.Unmarshal(jsonBytes, &diff)).To(Succeed())
+		Expect(diff["jobs"].([]interface{})[0]).To(HaveKeyWithValue("name", "fake1"))
+
+		markdownBytes, err := ioutil.ReadFile(filepath.Join(tmpdir, "output", "release-diff.md"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(markdownBytes)).To(HavePrefix("# fake 1.0.0 → 1.1.0\n"))
+		Expect(string(markdownBytes)).To(ContainSubstring("    * added property `port`\n"))
+	})
+
+	It("requires version files", func() {
+		Expect(runCLI(filepath.Join(tmpdir, "output"), "1.0.0", "1.1.0").ExitCode()).NotTo(Equal(0))
+	})
+})
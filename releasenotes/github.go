@@ -0,0 +1,66 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultGitHubBaseURL = "https://api.github.com"
+
+// GitHubSource finds release notes from the GitHub release of a tag. For
+// GitHub Enterprise, BaseURL should refer to the API endpoint (e.g.
+// https://github.example.com/api/v3).
+type GitHubSource struct {
+	BaseURL string
+	Token   string
+	Owner   string
+	Repo    string
+	Client  *http.Client
+}
+
+var _ NotesSource = GitHubSource{}
+
+func (s GitHubSource) Find(release Release) ([]byte, error) {
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultGitHubBaseURL
+	}
+
+	for _, tag := range release.Tags {
+		req, err := http.NewRequest(
+			"GET",
+			fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", strings.TrimSuffix(baseURL, "/"), url.PathEscape(s.Owner), url.PathEscape(s.Repo), url.PathEscape(tag)),
+			nil,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "building request")
+		}
+
+		if s.Token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("token %s", s.Token))
+		}
+
+		var githubRelease struct {
+			Body string `json:"body"`
+		}
+
+		err = getJSON(s.Client, req, &githubRelease)
+		if err == errNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "getting release for tag %s", tag)
+		}
+
+		return []byte(githubRelease.Body), nil
+	}
+
+	return nil, nil
+}
+
+func (s GitHubSource) String() string {
+	return fmt.Sprintf("github release notes of %s/%s", s.Owner, s.Repo)
+}
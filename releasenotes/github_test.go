@@ -0,0 +1,76 @@
+package releasenotes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/releasenotes"
+)
+
+var _ = Describe("GitHubSource", func() {
+	var server *httptest.Server
+	var requests []*http.Request
+	var subject GitHubSource
+
+	BeforeEach(func() {
+		requests = nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+
+			switch r.URL.Path {
+			case "/repos/dpb587/fake-release/releases/tags/v1.2.3":
+				w.Write([]byte(`{"body":"tagged notes"}`))
+			case "/repos/dpb587/fake-release/releases/tags/2.0.0":
+				w.Write([]byte(`{"body":"untagged notes"}`))
+			case "/repos/dpb587/fake-release/releases/tags/v9.9.9":
+				w.WriteHeader(http.StatusUnauthorized)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		subject = GitHubSource{
+			BaseURL: server.URL,
+			Token:   "fake-token",
+			Owner:   "dpb587",
+			Repo:    "fake-release",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("finds release notes of the first matching tag", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "1.2.3", Tags: []string{"v1.2.3", "1.2.3"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(notes)).To(Equal("tagged notes"))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("token fake-token"))
+	})
+
+	It("falls back to later tags", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "2.0.0", Tags: []string{"v2.0.0", "2.0.0"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(notes)).To(Equal("untagged notes"))
+
+		Expect(requests).To(HaveLen(2))
+	})
+
+	It("returns nothing when no release exists", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "3.0.0", Tags: []string{"v3.0.0", "3.0.0"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notes).To(BeNil())
+	})
+
+	It("errors on unexpected responses", func() {
+		_, err := subject.Find(Release{Name: "fake", Version: "9.9.9", Tags: []string{"v9.9.9"}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("401"))
+	})
+})
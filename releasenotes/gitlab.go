@@ -0,0 +1,64 @@
+package releasenotes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultGitLabBaseURL = "https://gitlab.com"
+
+// GitLabSource finds release notes from the GitLab release of a tag. Project
+// is the full path of the project (e.g. group/subgroup/project).
+type GitLabSource struct {
+	BaseURL string
+	Token   string
+	Project string
+	Client  *http.Client
+}
+
+var _ NotesSource = GitLabSource{}
+
+func (s GitLabSource) Find(release Release) ([]byte, error) {
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultGitLabBaseURL
+	}
+
+	for _, tag := range release.Tags {
+		req, err := http.NewRequest(
+			"GET",
+			fmt.Sprintf("%s/api/v4/projects/%s/releases/%s", strings.TrimSuffix(baseURL, "/"), url.PathEscape(s.Project), url.PathEscape(tag)),
+			nil,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "building request")
+		}
+
+		if s.Token != "" {
+			req.Header.Set("PRIVATE-TOKEN", s.Token)
+		}
+
+		var gitlabRelease struct {
+			Description string `json:"description"`
+		}
+
+		err = getJSON(s.Client, req, &gitlabRelease)
+		if err == errNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "getting release for tag %s", tag)
+		}
+
+		return []byte(gitlabRelease.Description), nil
+	}
+
+	return nil, nil
+}
+
+func (s GitLabSource) String() string {
+	return fmt.Sprintf("gitlab release notes of %s", s.Project)
+}
@@ -0,0 +1,59 @@
+package releasenotes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/releasenotes"
+)
+
+var _ = Describe("GitLabSource", func() {
+	var server *httptest.Server
+	var requests []*http.Request
+	var subject GitLabSource
+
+	BeforeEach(func() {
+		requests = nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+
+			switch r.URL.RawPath {
+			case "/api/v4/projects/group%2Fsubgroup%2Ffake-release/releases/v1.2.3":
+				w.Write([]byte(`{"description":"tagged notes"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		subject = GitLabSource{
+			BaseURL: server.URL,
+			Token:   "fake-token",
+			Project: "group/subgroup/fake-release",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("finds release notes using the encoded project path", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "1.2.3", Tags: []string{"v1.2.3", "1.2.3"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(notes)).To(Equal("tagged notes"))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Header.Get("PRIVATE-TOKEN")).To(Equal("fake-token"))
+	})
+
+	It("returns nothing when no release exists", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "3.0.0", Tags: []string{"v3.0.0", "3.0.0"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notes).To(BeNil())
+
+		Expect(requests).To(HaveLen(2))
+	})
+})
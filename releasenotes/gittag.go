@@ -0,0 +1,31 @@
+package releasenotes
+
+import (
+	"github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/pkg/errors"
+)
+
+// GitTagSource finds release notes from the message of an annotated tag.
+// Lightweight tags are ignored since they have no message of their own.
+type GitTagSource struct {
+	Repository *boshrelease.Repository
+}
+
+var _ NotesSource = GitTagSource{}
+
+func (s GitTagSource) Find(release Release) ([]byte, error) {
+	for _, tag := range release.Tags {
+		message, found, err := s.Repository.TagAnnotation(tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading tag %s", tag)
+		} else if found {
+			return []byte(message), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s GitTagSource) String() string {
+	return "git tag annotations"
+}
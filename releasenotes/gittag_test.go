@@ -0,0 +1,54 @@
+package releasenotes_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dpb587/bosh-release-resource/boshrelease"
+	"github.com/dpb587/bosh-release-resource/internal/testing"
+	. "github.com/dpb587/bosh-release-resource/releasenotes"
+)
+
+var _ = Describe("GitTagSource", func() {
+	var tmpdir string
+	var subject GitTagSource
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "bosh-release-resource-releasenotes")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = testing.RunCommands(
+			tmpdir,
+			[]string{
+				"git init .",
+				"git -c user.name=test -c user.email=test@localhost commit --allow-empty -m init",
+				"git -c user.name=test -c user.email=test@localhost tag -a -m 'annotated notes' v1.2.3",
+				"git tag v2.0.0",
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		subject = GitTagSource{Repository: boshrelease.NewLocalRepository(tmpdir)}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	It("finds annotated tag messages", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "1.2.3", Tags: []string{"v1.2.3", "1.2.3"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(notes)).To(Equal("annotated notes"))
+	})
+
+	It("ignores lightweight tags", func() {
+		notes, err := subject.Find(Release{Name: "fake", Version: "2.0.0", Tags: []string{"v2.0.0", "2.0.0"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notes).To(BeNil())
+	})
+})
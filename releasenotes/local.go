@@ -0,0 +1,35 @@
+package releasenotes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// LocalSource finds release notes committed to the release repository under
+// the releases/{name}/{name}-{version}.md convention.
+type LocalSource struct {
+	Dir string
+}
+
+var _ NotesSource = LocalSource{}
+
+func (s LocalSource) Find(release Release) ([]byte, error) {
+	bytes, err := ioutil.ReadFile(filepath.Join(s.Dir, "releases", release.Name, fmt.Sprintf("%s-%s.md", release.Name, release.Version)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "reading release notes")
+	}
+
+	return bytes, nil
+}
+
+func (s LocalSource) String() string {
+	return "local release notes"
+}
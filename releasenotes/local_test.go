@@ -0,0 +1,42 @@
+package releasenotes_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/dpb587/bosh-release-resource/releasenotes"
+)
+
+var _ = Describe("LocalSource", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "bosh-release-resource-releasenotes")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(tmpdir, "releases", "fake"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpdir, "releases", "fake", "fake-1.2.3.md"), []byte("local notes"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	It("finds committed release notes", func() {
+		notes, err := LocalSource{Dir: tmpdir}.Find(Release{Name: "fake", Version: "1.2.3"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(notes)).To(Equal("local notes"))
+	})
+
+	It("returns nothing when missing", func() {
+		notes, err := LocalSource{Dir: tmpdir}.Find(Release{Name: "fake", Version: "2.0.0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notes).To(BeNil())
+	})
+})
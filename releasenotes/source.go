@@ -0,0 +1,16 @@
+package releasenotes
+
+// Release identifies the version whose release notes are being loaded.
+type Release struct {
+	Name    string
+	Version string
+	Tags    []string
+}
+
+type NotesSource interface {
+	// Find returns the release notes, or nil if the source has none for the
+	// release.
+	Find(release Release) ([]byte, error)
+
+	String() string
+}